@@ -0,0 +1,39 @@
+package unitconversion
+
+// ConversionJob is a Quantity to convert to the unit To, an empty To converts to the preferred unit, set Explain to get an Explanation as with ConvertExplained
+type ConversionJob struct {
+	Input   Quantity
+	To      string
+	Explain bool
+}
+
+// BatchResult is the result of one ConversionJob, Err is set when the job failed
+type BatchResult struct {
+	ConversionResult
+	Err error
+}
+
+// ConvertBatch converts each job with ConvertQuantity, jobs with the same from and to units share one path lookup and a failing job does not stop the others
+func (converter *Converter) ConvertBatch(jobs []ConversionJob) (results []BatchResult) {
+	plans := map[[2]string]conversionPlan{}
+	results = make([]BatchResult, len(jobs))
+
+	for index, job := range jobs {
+		key := [2]string{job.Input.Unit, job.To}
+		plan, found := plans[key]
+		if !found {
+			plan = converter.planConversion(job.Input.Unit, job.To)
+			plans[key] = plan
+		}
+
+		results[index].ConversionResult, results[index].Err = converter.applyPlan(job.Input, plan)
+		if job.Explain && results[index].Err == nil {
+			results[index].Explanation, results[index].Err = converter.explainPlan(job.Input, plan)
+			if results[index].Err != nil {
+				results[index].ConversionResult = ConversionResult{}
+			}
+		}
+	}
+
+	return
+}
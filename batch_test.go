@@ -0,0 +1,42 @@
+package unitconversion
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConverterConvertBatch(test *testing.T) {
+	converter := Converter{
+		PreferredUnits: []string{"m"},
+		Conversions: []Conversion{
+			Conversion{From: "cm", To: "m", Formula: "magnitude / 100"},
+			Conversion{From: "m", To: "km", Formula: "magnitude / 1000"},
+		},
+	}
+
+	results := converter.ConvertBatch([]ConversionJob{
+		ConversionJob{Input: Quantity{Magnitude: 1000, Unit: "cm"}, To: "km"},
+		ConversionJob{Input: Quantity{Magnitude: 1, Unit: "g"}, To: "km"},
+		ConversionJob{Input: Quantity{Magnitude: 2000, Unit: "cm"}, To: "km"},
+		ConversionJob{Input: Quantity{Magnitude: 300, Unit: "cm"}},
+	})
+
+	assert.Len(test, results, 4)
+	assert.NoError(test, results[0].Err)
+	assert.Equal(test, Quantity{Magnitude: 0.01, Unit: "km"}, results[0].Quantity)
+	assert.Error(test, results[1].Err)
+	assert.Equal(test, ConversionResult{}, results[1].ConversionResult)
+	assert.NoError(test, results[2].Err)
+	assert.Equal(test, Quantity{Magnitude: 0.02, Unit: "km"}, results[2].Quantity)
+	assert.Equal(test, []string{"cm", "m", "km"}, results[2].Path)
+	assert.NoError(test, results[3].Err)
+	assert.Equal(test, Quantity{Magnitude: 3, Unit: "m"}, results[3].Quantity)
+	assert.Len(test, results[3].Warnings, 1)
+}
+
+func TestConverterConvertBatchWithoutJobs(test *testing.T) {
+	converter := Converter{}
+
+	assert.Equal(test, []BatchResult{}, converter.ConvertBatch([]ConversionJob{}))
+}
@@ -0,0 +1,168 @@
+package unitconversion
+
+import (
+	"fmt"
+)
+
+// The methods in this file change a Converter from Go code, e.g. to assemble one in a test. Each change is validated before it is applied and the paths in Converter.PathCache that are affected by it are dropped. They must not be called while the Converter is used from other goroutines.
+
+// conversionIndex returns the index of the conversion from the unit from to the unit to in Converter.Conversions, or -1
+func (converter *Converter) conversionIndex(from string, to string) int {
+	for index := range converter.Conversions {
+		if converter.Conversions[index].From == from && converter.Conversions[index].To == to {
+			return index
+		}
+	}
+
+	return -1
+}
+
+// invalidatePaths drops the cached paths that go through any of the conversions in Converter.Conversions from the index start up to but not including end
+func (converter *Converter) invalidatePaths(start int, end int) {
+	affected := map[*Conversion]bool{}
+	for index := start; index < end; index++ {
+		affected[&converter.Conversions[index]] = true
+	}
+
+	lock := converter.cacheLock()
+	lock.Lock()
+	defer lock.Unlock()
+
+	for key, path := range converter.PathCache {
+		for _, conversion := range path {
+			if affected[conversion] {
+				delete(converter.PathCache, key)
+				break
+			}
+		}
+	}
+}
+
+// AddConversion tests conversion with its test fixtures and adds it, a conversion in between the same units must be replaced with ReplaceConversion instead
+func (converter *Converter) AddConversion(conversion Conversion) (err error) {
+	if converter.conversionIndex(conversion.From, conversion.To) >= 0 {
+		err = fmt.Errorf("The conversion from %q to %q is already defined, use ReplaceConversion to replace it", conversion.From, conversion.To)
+		return
+	}
+
+	if err = conversion.Test(); err != nil {
+		return
+	}
+
+	// The cached paths point into Conversions, when append moves it to a new array they would keep pointing at the old one
+	if len(converter.Conversions) == cap(converter.Conversions) {
+		converter.newPathCache()
+	}
+
+	converter.Conversions = append(converter.Conversions, conversion)
+
+	return
+}
+
+// ReplaceConversion tests conversion with its test fixtures and replaces the conversion in between the same units, it fails with a ConversionNotDefinedError when there is none
+func (converter *Converter) ReplaceConversion(conversion Conversion) (err error) {
+	index := converter.conversionIndex(conversion.From, conversion.To)
+	if index < 0 {
+		err = &ConversionNotDefinedError{From: conversion.From, To: conversion.To}
+		return
+	}
+
+	if err = conversion.Test(); err != nil {
+		return
+	}
+
+	converter.invalidatePaths(index, index+1)
+	converter.Conversions[index] = conversion
+
+	return
+}
+
+// removeConversionAt removes the conversion at index, the conversions after it move one step so the paths through them are dropped as well
+func (converter *Converter) removeConversionAt(index int) {
+	converter.invalidatePaths(index, len(converter.Conversions))
+	converter.Conversions = append(converter.Conversions[:index], converter.Conversions[index+1:]...)
+}
+
+// RemoveConversion removes the conversion from the unit from to the unit to, the conversion in the other direction is kept, it fails with a ConversionNotDefinedError when there is none
+func (converter *Converter) RemoveConversion(from string, to string) (err error) {
+	index := converter.conversionIndex(from, to)
+	if index < 0 {
+		err = &ConversionNotDefinedError{From: from, To: to}
+		return
+	}
+
+	converter.removeConversionAt(index)
+
+	return
+}
+
+// AddUnit adds a unit definition, its name and aliases must not be used by another unit
+func (converter *Converter) AddUnit(definition UnitDefinition) (err error) {
+	if definition.Name == "" {
+		err = fmt.Errorf("The unit has no name")
+		return
+	}
+
+	if _, defined := converter.unitDefinition(definition.Name); defined {
+		err = fmt.Errorf("The unit %q is already defined", definition.Name)
+		return
+	}
+
+	converter.UnitDefinitions = append(converter.UnitDefinitions, definition)
+	if _, err = converter.testUnits(); err != nil {
+		converter.UnitDefinitions = converter.UnitDefinitions[:len(converter.UnitDefinitions)-1]
+	}
+
+	return
+}
+
+// RemoveUnit removes a unit together with its definition, every conversion from or to it and its place in Converter.PreferredUnits
+func (converter *Converter) RemoveUnit(unit string) (err error) {
+	unit = converter.ResolveUnit(unit)
+	if !converter.hasUnit(unit) {
+		err = &UnknownUnitError{Unit: unit}
+		return
+	}
+
+	for index := len(converter.Conversions) - 1; index >= 0; index-- {
+		if converter.Conversions[index].From == unit || converter.Conversions[index].To == unit {
+			converter.removeConversionAt(index)
+		}
+	}
+
+	definitions := []UnitDefinition{}
+	for _, definition := range converter.UnitDefinitions {
+		if definition.Name != unit {
+			definitions = append(definitions, definition)
+		}
+	}
+	converter.UnitDefinitions = definitions
+
+	preferredUnits := []string{}
+	for _, preferredUnit := range converter.PreferredUnits {
+		if preferredUnit != unit {
+			preferredUnits = append(preferredUnits, preferredUnit)
+		}
+	}
+	converter.PreferredUnits = preferredUnits
+
+	return
+}
+
+// SetPreferredUnits replaces Converter.PreferredUnits, every unit must be known and the last unit that a quantity can be converted to is used
+func (converter *Converter) SetPreferredUnits(units ...string) (err error) {
+	for _, unit := range units {
+		if !converter.hasUnit(converter.ResolveUnit(unit)) {
+			err = &UnknownUnitError{Unit: unit}
+			return
+		}
+	}
+
+	preferredUnits := []string{}
+	for _, unit := range units {
+		preferredUnits = append(preferredUnits, converter.ResolveUnit(unit))
+	}
+	converter.PreferredUnits = preferredUnits
+
+	return
+}
@@ -0,0 +1,118 @@
+package unitconversion
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newBuilderTestConverter(test *testing.T) (converter Converter) {
+	for _, conversion := range []Conversion{
+		{From: "km", To: "m", Formula: "magnitude * 1000", TestFixtures: []ConversionTestFixture{{Input: 1, Expected: 1000}}},
+		{From: "m", To: "cm", Formula: "magnitude * 100", TestFixtures: []ConversionTestFixture{{Input: 1, Expected: 100}}},
+	} {
+		assert.NoError(test, converter.AddConversion(conversion))
+	}
+
+	return
+}
+
+func TestConverterAddConversion(test *testing.T) {
+	converter := newBuilderTestConverter(test)
+
+	output, err := converter.Convert(Quantity{Magnitude: 2, Unit: "km"}, "cm")
+	assert.NoError(test, err)
+	assert.Equal(test, Quantity{Magnitude: 200000, Unit: "cm"}, output)
+
+	err = converter.AddConversion(Conversion{From: "km", To: "m", Formula: "magnitude * 1000", TestFixtures: []ConversionTestFixture{{Input: 1, Expected: 1000}}})
+	assert.Error(test, err)
+
+	err = converter.AddConversion(Conversion{From: "mm", To: "m", Formula: "magnitude / 100", TestFixtures: []ConversionTestFixture{{Input: 1000, Expected: 1}}})
+	assert.True(test, errors.Is(err, ErrFixtureFailed))
+	assert.Len(test, converter.Conversions, 2)
+}
+
+func TestConverterReplaceConversion(test *testing.T) {
+	converter := newBuilderTestConverter(test)
+
+	_, err := converter.Convert(Quantity{Magnitude: 1, Unit: "km"}, "cm")
+	assert.NoError(test, err)
+	assert.Len(test, converter.PathCache, 1)
+
+	err = converter.ReplaceConversion(Conversion{From: "m", To: "cm", Formula: "magnitude * 10", TestFixtures: []ConversionTestFixture{{Input: 1, Expected: 10}}})
+	assert.NoError(test, err)
+	assert.Empty(test, converter.PathCache)
+
+	output, err := converter.Convert(Quantity{Magnitude: 1, Unit: "km"}, "cm")
+	assert.NoError(test, err)
+	assert.Equal(test, 10000.0, output.Magnitude)
+
+	err = converter.ReplaceConversion(Conversion{From: "cm", To: "m", Formula: "magnitude / 100", TestFixtures: []ConversionTestFixture{{Input: 100, Expected: 1}}})
+	assert.True(test, errors.Is(err, ErrConversionNotDefined))
+	assert.False(test, errors.Is(err, ErrNoPath))
+}
+
+func TestConverterReplaceConversionKeepsUnaffectedPaths(test *testing.T) {
+	converter := newBuilderTestConverter(test)
+
+	_, err := converter.Convert(Quantity{Magnitude: 1, Unit: "m"}, "cm")
+	assert.NoError(test, err)
+	_, err = converter.Convert(Quantity{Magnitude: 1, Unit: "km"}, "m")
+	assert.NoError(test, err)
+
+	err = converter.ReplaceConversion(Conversion{From: "km", To: "m", Formula: "magnitude * 1000", TestFixtures: []ConversionTestFixture{{Input: 2, Expected: 2000}}})
+	assert.NoError(test, err)
+	assert.Len(test, converter.PathCache, 1)
+	assert.Contains(test, converter.PathCache, "m => cm")
+}
+
+func TestConverterRemoveConversion(test *testing.T) {
+	converter := newBuilderTestConverter(test)
+
+	_, err := converter.Convert(Quantity{Magnitude: 1, Unit: "m"}, "cm")
+	assert.NoError(test, err)
+	_, err = converter.Convert(Quantity{Magnitude: 1, Unit: "km"}, "m")
+	assert.NoError(test, err)
+
+	assert.NoError(test, converter.RemoveConversion("km", "m"))
+	assert.Len(test, converter.PathCache, 0)
+
+	output, err := converter.Convert(Quantity{Magnitude: 1, Unit: "m"}, "cm")
+	assert.NoError(test, err)
+	assert.Equal(test, 100.0, output.Magnitude)
+
+	_, err = converter.Convert(Quantity{Magnitude: 1, Unit: "km"}, "m")
+	assert.True(test, errors.Is(err, ErrUnknownUnit))
+
+	assert.True(test, errors.Is(converter.RemoveConversion("km", "m"), ErrConversionNotDefined))
+}
+
+func TestConverterAddAndRemoveUnit(test *testing.T) {
+	converter := newBuilderTestConverter(test)
+
+	assert.NoError(test, converter.AddUnit(UnitDefinition{Name: "m", Dimension: "length", Aliases: []string{"meter"}}))
+	assert.Error(test, converter.AddUnit(UnitDefinition{Name: "m"}))
+	assert.Error(test, converter.AddUnit(UnitDefinition{Name: "metre", Aliases: []string{"meter"}}))
+	assert.Len(test, converter.UnitDefinitions, 1)
+
+	assert.NoError(test, converter.SetPreferredUnits("meter", "cm"))
+	assert.Equal(test, []string{"m", "cm"}, converter.PreferredUnits)
+	assert.True(test, errors.Is(converter.SetPreferredUnits("ft"), ErrUnknownUnit))
+
+	output, err := converter.ConvertToPreferredUnit(Quantity{Magnitude: 1, Unit: "km"})
+	assert.NoError(test, err)
+	assert.Equal(test, Quantity{Magnitude: 100000, Unit: "cm"}, output)
+
+	assert.NoError(test, converter.RemoveUnit("cm"))
+	assert.Len(test, converter.Conversions, 1)
+	assert.Equal(test, []string{"m"}, converter.PreferredUnits)
+
+	output, err = converter.ConvertToPreferredUnit(Quantity{Magnitude: 1, Unit: "km"})
+	assert.NoError(test, err)
+	assert.Equal(test, Quantity{Magnitude: 1000, Unit: "m"}, output)
+
+	assert.NoError(test, converter.RemoveUnit("meter"))
+	assert.Empty(test, converter.UnitDefinitions)
+	assert.True(test, errors.Is(converter.RemoveUnit("m"), ErrUnknownUnit))
+}
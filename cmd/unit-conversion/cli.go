@@ -0,0 +1,536 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	unitconversion "github.com/tirithen/unit-conversion"
+)
+
+// Exit codes returned by run
+const (
+	exitOK            = 0
+	exitFailed        = 1
+	exitUsage         = 2
+	exitInvalidConfig = 3
+)
+
+const defaultConfigPath = "./converter.yml"
+const defaultAddress = ":8080"
+
+const usage = `Usage: unit-conversion <command> [flags] [arguments]
+
+Commands:
+  serve [--config file] [--addr address] [--degraded]
+                                                run the HTTP service (default when no command is given)
+  convert [--json] [--explain] value from [to]  convert a quantity, to defaults to the preferred unit
+  convert-json [--json] [--annotate] [--string-magnitudes]
+                                                convert a JSON document from stdin to stdout
+  validate [--json] [--strict] [--tolerance t] [file...]
+                                                test and lint a configuration file
+  units [--json] [prefix]                       list the known units
+  path [--json] from to                         show the conversions in between two units
+  graph [--format dot|mermaid]                  export all conversions as a graph
+  migrate [file]                                write a configuration file in the latest format to stdout
+  selftest [--json]                             run the test fixtures of every conversion and list the results
+
+The configuration is loaded on top of the default unit library that is built in, from --config or ./converter.yml if it exists. Give --config more than once, or a directory, to overlay several files. validate also takes the files as arguments. Use --no-defaults to only use the given files.
+
+Flags can be given before or after the arguments, negative numbers such as -40 are read as arguments. Arguments after -- are never read as flags.
+
+Exit codes: 0 success, 1 a conversion failed or the service stopped, 2 bad usage, 3 the configuration could not be loaded.
+`
+
+// configPathsFlag is a flag that can be given more than once, the configuration files are loaded in the order they are given
+type configPathsFlag []string
+
+func (paths *configPathsFlag) String() string {
+	return strings.Join(*paths, ", ")
+}
+
+func (paths *configPathsFlag) Set(path string) error {
+	*paths = append(*paths, path)
+	return nil
+}
+
+// cli holds the streams and common flags of a command
+type cli struct {
+	stdin       io.Reader
+	stdout      io.Writer
+	stderr      io.Writer
+	flags       *flag.FlagSet
+	configPaths configPathsFlag
+	json        bool
+	noDefaults  bool
+}
+
+// run runs the command in args, the first argument is the command name and the rest are its flags and arguments, the returned value is the exit code
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	command := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command = args[0]
+		args = args[1:]
+	}
+
+	commands := map[string]func(cli *cli, args []string) int{
+		"serve":        (*cli).serve,
+		"convert":      (*cli).convert,
+		"convert-json": (*cli).convertJSON,
+		"validate":     (*cli).validate,
+		"units":        (*cli).units,
+		"path":         (*cli).path,
+		"graph":        (*cli).graph,
+		"migrate":      (*cli).migrate,
+		"selftest":     (*cli).selfTest,
+	}
+
+	if command == "help" {
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	}
+
+	handler, found := commands[command]
+	if !found {
+		fmt.Fprintf(stderr, "Unknown command %q\n\n%s", command, usage)
+		return exitUsage
+	}
+
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, usage) }
+	commandLine := &cli{stdin: stdin, stdout: stdout, stderr: stderr, flags: flags}
+	flags.Var(&commandLine.configPaths, "config", "a configuration file or directory, can be given more than once")
+	flags.BoolVar(&commandLine.json, "json", false, "write the output as JSON")
+	flags.BoolVar(&commandLine.noDefaults, "no-defaults", false, "do not load the configuration on top of the default unit library")
+
+	return handler(commandLine, args)
+}
+
+// splitArguments separates the flags in args from the other arguments so that flags can also be given after the arguments, numbers such as -40 are arguments and everything after -- is an argument
+func (cli *cli) splitArguments(args []string) (flags []string, arguments []string) {
+	for index := 0; index < len(args); index++ {
+		arg := args[index]
+		if arg == "--" {
+			arguments = append(arguments, args[index+1:]...)
+			return
+		}
+
+		if _, err := strconv.ParseFloat(arg, 64); err == nil || !strings.HasPrefix(arg, "-") || arg == "-" {
+			arguments = append(arguments, arg)
+			continue
+		}
+
+		flags = append(flags, arg)
+		name := strings.TrimLeft(arg, "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+
+		// The value of a flag that is not a boolean is the next argument, even when it looks like a number
+		definition := cli.flags.Lookup(name)
+		if definition == nil || index+1 == len(args) {
+			continue
+		}
+
+		if boolFlag, isBool := definition.Value.(interface{ IsBoolFlag() bool }); !isBool || !boolFlag.IsBoolFlag() {
+			index++
+			flags = append(flags, args[index])
+		}
+	}
+
+	return
+}
+
+// parse parses the flags in args and checks that the number of remaining arguments is in between min and max, a negative max allows any number of arguments
+func (cli *cli) parse(args []string, min int, max int) (arguments []string, ok bool) {
+	flags, arguments := cli.splitArguments(args)
+	if cli.flags.Parse(flags) != nil {
+		return
+	}
+
+	// Without --config ./converter.yml is optional as long as there is the default unit library to fall back on
+	if len(cli.configPaths) == 0 {
+		if _, err := os.Stat(defaultConfigPath); err == nil || cli.noDefaults {
+			cli.configPaths = configPathsFlag{defaultConfigPath}
+		}
+	}
+
+	if len(arguments) < min || (max >= 0 && len(arguments) > max) {
+		fmt.Fprintf(cli.stderr, "Wrong number of arguments for %s\n\n%s", cli.flags.Name(), usage)
+		return
+	}
+
+	ok = true
+
+	return
+}
+
+// configName describes the configuration that is loaded, for messages
+func (cli *cli) configName() string {
+	if len(cli.configPaths) == 0 {
+		return "the default unit library"
+	}
+
+	return cli.configPaths.String()
+}
+
+func (cli *cli) loadOptions() unitconversion.LoadOptions {
+	return unitconversion.LoadOptions{Defaults: !cli.noDefaults}
+}
+
+func (cli *cli) loadConverter() (converter unitconversion.JSONConverter, ok bool) {
+	baseConverter, _, err := unitconversion.LoadConverterFiles(cli.loadOptions(), cli.configPaths...)
+	if err != nil {
+		fmt.Fprintf(cli.stderr, "Unable to load %s: %v\n", cli.configName(), err)
+		return
+	}
+
+	converter = unitconversion.NewJSONConverter(baseConverter)
+	ok = true
+
+	return
+}
+
+func (cli *cli) writeJSON(value interface{}) {
+	encoder := json.NewEncoder(cli.stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+}
+
+func (cli *cli) writeErrors(errors []error) {
+	if cli.json {
+		encoder := json.NewEncoder(cli.stderr)
+		encoder.Encode(errorResponse{Errors: quantityErrors(errors)})
+		return
+	}
+
+	for _, err := range errors {
+		fmt.Fprintln(cli.stderr, err)
+	}
+}
+
+func (cli *cli) serve(args []string) int {
+	address := cli.flags.String("addr", defaultAddress, "the address to listen on")
+	degraded := cli.flags.Bool("degraded", false, "leave out conversions that fail their tests instead of refusing to start")
+	if _, ok := cli.parse(args, 0, 0); !ok {
+		return exitUsage
+	}
+
+	options := cli.loadOptions()
+	options.Degraded = *degraded
+
+	return serve(cli.configPaths, options, *address, cli.stderr)
+}
+
+func (cli *cli) convert(args []string) int {
+	explain := cli.flags.Bool("explain", false, "include the conversion steps")
+	arguments, ok := cli.parse(args, 2, 3)
+	if !ok {
+		return exitUsage
+	}
+
+	magnitude, err := strconv.ParseFloat(arguments[0], 64)
+	if err != nil {
+		fmt.Fprintf(cli.stderr, "The value must be a number, got %q\n", arguments[0])
+		return exitUsage
+	}
+
+	converter, ok := cli.loadConverter()
+	if !ok {
+		return exitInvalidConfig
+	}
+
+	to := ""
+	if len(arguments) == 3 {
+		to = arguments[2]
+	}
+
+	convert := converter.ConvertQuantity
+	if *explain {
+		convert = converter.ConvertExplained
+	}
+
+	result, err := convert(unitconversion.Quantity{Magnitude: magnitude, Unit: arguments[1]}, to)
+	if err != nil {
+		cli.writeErrors([]error{unitconversion.NewQuantityError("", arguments[1], unitconversion.ErrorCodeOf(err), err)})
+		return exitFailed
+	}
+
+	if cli.json {
+		cli.writeJSON(result)
+		return exitOK
+	}
+
+	for _, warning := range result.Warnings {
+		fmt.Fprintln(cli.stderr, "warning:", warning)
+	}
+
+	if result.Explanation != nil {
+		for _, hop := range result.Explanation.Hops {
+			fmt.Fprintf(cli.stdout, "%s -> %s: %s = %v\n", hop.From, hop.To, hop.Formula, hop.Magnitude)
+		}
+	}
+
+	fmt.Fprintf(cli.stdout, "%v %s\n", result.Magnitude, result.Unit)
+
+	return exitOK
+}
+
+func (cli *cli) convertJSON(args []string) int {
+	annotate := cli.flags.Bool("annotate", false, "keep the original values in each converted object")
+	stringMagnitudes := cli.flags.Bool("string-magnitudes", false, "also convert magnitudes written as strings")
+	if _, ok := cli.parse(args, 0, 0); !ok {
+		return exitUsage
+	}
+
+	converter, ok := cli.loadConverter()
+	if !ok {
+		return exitInvalidConfig
+	}
+
+	converter.Annotate = *annotate
+	converter.AcceptStringMagnitudes = *stringMagnitudes
+
+	errors := converter.ConvertStream(cli.stdin, cli.stdout)
+	if len(errors) > 0 {
+		cli.writeErrors(errors)
+		return exitFailed
+	}
+
+	return exitOK
+}
+
+// validationResult is written by the validate command with --json, Issues are found by Converter.Lint and only make the configuration invalid with --strict
+type validationResult struct {
+	Valid  bool                       `json:"valid"`
+	Errors []string                   `json:"errors"`
+	Issues []unitconversion.LintIssue `json:"issues"`
+}
+
+func (cli *cli) validate(args []string) int {
+	strict := cli.flags.Bool("strict", false, "treat lint issues as errors")
+	tolerance := cli.flags.Float64("tolerance", unitconversion.DefaultLintTolerance, "the relative difference allowed by the lint checks")
+	arguments, ok := cli.parse(args, 0, -1)
+	if !ok {
+		return exitUsage
+	}
+
+	if len(arguments) > 0 {
+		cli.configPaths = arguments
+	}
+
+	result := validationResult{Valid: true, Errors: []string{}, Issues: []unitconversion.LintIssue{}}
+	converter, _, err := unitconversion.LoadConverterFiles(cli.loadOptions(), cli.configPaths...)
+
+	if configErrors, ok := err.(unitconversion.ConfigErrors); ok {
+		result.Valid = false
+		for _, configError := range configErrors {
+			result.Errors = append(result.Errors, configError.Error())
+		}
+	} else if err != nil {
+		result.Valid = false
+		result.Errors = append(result.Errors, err.Error())
+	} else {
+		result.Issues = converter.Lint(*tolerance)
+		result.Valid = !*strict || len(result.Issues) == 0
+	}
+
+	if cli.json {
+		cli.writeJSON(result)
+	} else {
+		for _, message := range result.Errors {
+			fmt.Fprintln(cli.stderr, message)
+		}
+
+		for _, issue := range result.Issues {
+			fmt.Fprintf(cli.stderr, "warning: %s\n", issue)
+		}
+
+		if result.Valid {
+			fmt.Fprintf(cli.stdout, "%s is valid\n", cli.configName())
+		}
+	}
+
+	if !result.Valid {
+		return exitInvalidConfig
+	}
+
+	return exitOK
+}
+
+func (cli *cli) units(args []string) int {
+	arguments, ok := cli.parse(args, 0, 1)
+	if !ok {
+		return exitUsage
+	}
+
+	converter, ok := cli.loadConverter()
+	if !ok {
+		return exitInvalidConfig
+	}
+
+	prefix := ""
+	if len(arguments) == 1 {
+		prefix = arguments[0]
+	}
+
+	units := converter.SearchUnits(prefix)
+	if cli.json {
+		cli.writeJSON(unitsResponse{Units: units})
+		return exitOK
+	}
+
+	for _, unit := range units {
+		fmt.Fprintf(cli.stdout, "%s\t%s\t%s\n", unit.Name, unit.Dimension, strings.Join(unit.Aliases, ", "))
+	}
+
+	return exitOK
+}
+
+// pathResult is written by the path command with --json
+type pathResult struct {
+	From  string                                `json:"from"`
+	To    string                                `json:"to"`
+	Steps []unitconversion.ConversionStepSource `json:"steps"`
+}
+
+func (cli *cli) path(args []string) int {
+	arguments, ok := cli.parse(args, 2, 2)
+	if !ok {
+		return exitUsage
+	}
+
+	converter, ok := cli.loadConverter()
+	if !ok {
+		return exitInvalidConfig
+	}
+
+	path, err := converter.FindPath(arguments[0], arguments[1])
+	if err != nil {
+		cli.writeErrors([]error{unitconversion.NewQuantityError("", arguments[0], unitconversion.ErrorCodeOf(err), err)})
+		return exitFailed
+	}
+
+	result := pathResult{From: converter.ResolveUnit(arguments[0]), To: converter.ResolveUnit(arguments[1]), Steps: []unitconversion.ConversionStepSource{}}
+	for _, conversion := range path {
+		result.Steps = append(result.Steps, unitconversion.ConversionStepSource{From: conversion.From, To: conversion.To, Formula: conversion.Description()})
+	}
+
+	if cli.json {
+		cli.writeJSON(result)
+		return exitOK
+	}
+
+	for _, step := range result.Steps {
+		fmt.Fprintf(cli.stdout, "%s -> %s: %s\n", step.From, step.To, step.Formula)
+	}
+
+	return exitOK
+}
+
+func (cli *cli) graph(args []string) int {
+	format := cli.flags.String("format", string(unitconversion.GraphFormatDOT), "dot or mermaid")
+	if _, ok := cli.parse(args, 0, 0); !ok {
+		return exitUsage
+	}
+
+	converter, ok := cli.loadConverter()
+	if !ok {
+		return exitInvalidConfig
+	}
+
+	graph, err := converter.Graph(unitconversion.GraphFormat(*format))
+	if err != nil {
+		fmt.Fprintln(cli.stderr, err)
+		return exitUsage
+	}
+
+	fmt.Fprint(cli.stdout, graph)
+
+	return exitOK
+}
+
+func (cli *cli) migrate(args []string) int {
+	arguments, ok := cli.parse(args, 0, 1)
+	if !ok {
+		return exitUsage
+	}
+
+	path := defaultConfigPath
+	if len(cli.configPaths) > 0 {
+		path = cli.configPaths[0]
+	}
+
+	if len(arguments) > 0 {
+		path = arguments[0]
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(cli.stderr, err)
+		return exitInvalidConfig
+	}
+
+	migrated, err := unitconversion.MigrateConfig(raw)
+	if err != nil {
+		fmt.Fprintf(cli.stderr, "Unable to migrate %s: %v\n", path, err)
+		return exitInvalidConfig
+	}
+
+	cli.stdout.Write(migrated)
+
+	return exitOK
+}
+
+func (cli *cli) selfTest(args []string) int {
+	if _, ok := cli.parse(args, 0, 0); !ok {
+		return exitUsage
+	}
+
+	// Failing conversions are excluded rather than failing the load, so that all of them are reported at once
+	options := cli.loadOptions()
+	options.Degraded = true
+	converter, _, err := unitconversion.LoadConverterFiles(options, cli.configPaths...)
+	if err != nil {
+		fmt.Fprintf(cli.stderr, "Unable to load %s: %v\n", cli.configName(), err)
+		return exitInvalidConfig
+	}
+
+	report := converter.TestReport()
+	if cli.json {
+		cli.writeJSON(report)
+	} else {
+		for _, result := range report.Conversions {
+			if result.Passed {
+				continue
+			}
+
+			fmt.Fprintf(cli.stdout, "FAIL %s -> %s: %s\n", result.From, result.To, result.Formula)
+			if result.Error != "" {
+				fmt.Fprintf(cli.stdout, "    %s\n", result.Error)
+			}
+
+			for _, fixture := range result.Fixtures {
+				if fixture.Error != "" {
+					fmt.Fprintf(cli.stdout, "    input %v: %s\n", fixture.Input, fixture.Error)
+				} else if !fixture.Passed {
+					fmt.Fprintf(cli.stdout, "    input %v: expected %v but got %v, off by %v\n", fixture.Input, fixture.Expected, fixture.Actual, fixture.Deviation)
+				}
+			}
+		}
+
+		fmt.Fprintf(cli.stdout, "%d passed, %d failed\n", report.Passed, report.Failed)
+	}
+
+	if !report.OK() {
+		return exitInvalidConfig
+	}
+
+	return exitOK
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func runCLI(stdin string, args ...string) (exitCode int, stdout string, stderr string) {
+	var output, errors bytes.Buffer
+	exitCode = run(args, strings.NewReader(stdin), &output, &errors)
+
+	return exitCode, output.String(), errors.String()
+}
+
+func TestRunConvert(test *testing.T) {
+	exitCode, stdout, _ := runCLI("", "convert", "10", "in", "cm")
+	assert.Equal(test, exitOK, exitCode)
+	assert.Equal(test, "25.4 cm\n", stdout)
+
+	exitCode, stdout, stderr := runCLI("", "convert", "--json", "1500", "mg")
+	assert.Equal(test, exitOK, exitCode)
+	assert.JSONEq(test, `{
+		"magnitude": 1.5,
+		"unit": "g",
+		"path": ["mg", "g"],
+		"warnings": ["No unit to convert to was given, the preferred unit \"g\" was used"]
+	}`, stdout)
+	assert.Empty(test, stderr)
+}
+
+func TestRunConvertWithNegativeValueAndTrailingFlags(test *testing.T) {
+	exitCode, stdout, stderr := runCLI("", "convert", "-40", "Cel", "[degF]")
+	assert.Equal(test, exitOK, exitCode, stderr)
+	assert.Equal(test, "-40 °F\n", stdout)
+
+	exitCode, stdout, stderr = runCLI("", "convert", "10", "in", "cm", "--json")
+	assert.Equal(test, exitOK, exitCode, stderr)
+	assert.Contains(test, stdout, `"magnitude": 25.4`)
+
+	exitCode, stdout, _ = runCLI("", "convert", "--", "-40", "Cel", "[degF]")
+	assert.Equal(test, exitOK, exitCode)
+	assert.Equal(test, "-40 °F\n", stdout)
+
+	exitCode, _, stderr = runCLI("", "convert", "10", "in", "cm", "--unknown")
+	assert.Equal(test, exitUsage, exitCode)
+	assert.Contains(test, stderr, "flag provided but not defined: -unknown")
+}
+
+func TestFailRunConvert(test *testing.T) {
+	exitCode, _, stderr := runCLI("", "convert", "10", "in", "kg")
+	assert.Equal(test, exitFailed, exitCode)
+	assert.Contains(test, stderr, "no_path")
+
+	exitCode, _, _ = runCLI("", "convert", "ten", "in", "cm")
+	assert.Equal(test, exitUsage, exitCode)
+
+	exitCode, _, _ = runCLI("", "convert", "10")
+	assert.Equal(test, exitUsage, exitCode)
+
+	exitCode, _, _ = runCLI("", "convert", "--config", "missing.yml", "10", "in", "cm")
+	assert.Equal(test, exitInvalidConfig, exitCode)
+}
+
+func TestRunConvertJSON(test *testing.T) {
+	exitCode, stdout, stderr := runCLI(`{"size": {"magnitude": 1, "unit": "km"}}`, "convert-json")
+	assert.Equal(test, exitOK, exitCode)
+	assert.JSONEq(test, `{"size": {"magnitude": 0.001, "unit": "m"}}`, stdout)
+	assert.Empty(test, stderr)
+
+	exitCode, stdout, stderr = runCLI(`[{"magnitude": 1, "unit": "parsec"}]`, "convert-json", "--json")
+	assert.Equal(test, exitFailed, exitCode)
+	assert.JSONEq(test, `[{"magnitude": 1, "unit": "parsec"}]`, stdout)
+	assert.Contains(test, stderr, `"code":"no_preferred_unit"`)
+}
+
+func TestRunValidate(test *testing.T) {
+	exitCode, stdout, stderr := runCLI("", "validate", "converter.yml")
+	assert.Equal(test, exitOK, exitCode)
+	assert.Equal(test, "converter.yml is valid\n", stdout)
+	assert.Contains(test, stderr, "warning: round_trip:")
+
+	exitCode, _, _ = runCLI("", "validate", "--strict", "converter.yml")
+	assert.Equal(test, exitInvalidConfig, exitCode)
+
+	exitCode, stdout, _ = runCLI("", "validate", "--json", "--tolerance", "1e-6", "converter.yml")
+	assert.Equal(test, exitOK, exitCode)
+	assert.NotContains(test, stdout, "round_trip")
+
+	exitCode, _, stderr = runCLI("", "validate", "missing.yml")
+	assert.Equal(test, exitInvalidConfig, exitCode)
+	assert.Contains(test, stderr, "missing.yml")
+}
+
+func TestRunUnitsAndPath(test *testing.T) {
+	exitCode, stdout, _ := runCLI("", "units", "inch")
+	assert.Equal(test, exitOK, exitCode)
+	assert.Equal(test, "in\tlength\tinch, [in_i]\n", stdout)
+
+	exitCode, stdout, _ = runCLI("", "path", "in", "cm")
+	assert.Equal(test, exitOK, exitCode)
+	assert.Equal(test, "in -> m: magnitude * 0.0254\nm -> cm: magnitude * 100\n", stdout)
+
+	exitCode, _, _ = runCLI("", "path", "in", "kg")
+	assert.Equal(test, exitFailed, exitCode)
+}
+
+func TestRunGraph(test *testing.T) {
+	exitCode, stdout, _ := runCLI("", "graph", "--format", "mermaid")
+	assert.Equal(test, exitOK, exitCode)
+	assert.Contains(test, stdout, "flowchart LR")
+
+	exitCode, _, _ = runCLI("", "graph", "--format", "svg")
+	assert.Equal(test, exitUsage, exitCode)
+}
+
+func TestFailRunWithUnknownCommand(test *testing.T) {
+	exitCode, _, stderr := runCLI("", "transmogrify")
+	assert.Equal(test, exitUsage, exitCode)
+	assert.Contains(test, stderr, "Usage:")
+}
+
+func TestRunMigrate(test *testing.T) {
+	exitCode, stdout, _ := runCLI("", "migrate", "converter.yml")
+	assert.Equal(test, exitOK, exitCode)
+	assert.Contains(test, stdout, "version: 1\n")
+
+	exitCode, _, stderr := runCLI("", "migrate", "missing.yml")
+	assert.Equal(test, exitInvalidConfig, exitCode)
+	assert.Contains(test, stderr, "missing.yml")
+}
+
+func TestRunWithoutConfigUsesDefaults(test *testing.T) {
+	directory, err := ioutil.TempDir("", "unit-conversion")
+	assert.NoError(test, err)
+	defer os.RemoveAll(directory)
+
+	workingDirectory, err := os.Getwd()
+	assert.NoError(test, err)
+	assert.NoError(test, os.Chdir(directory))
+	defer os.Chdir(workingDirectory)
+
+	exitCode, stdout, _ := runCLI("", "convert", "1", "ft", "cm")
+	assert.Equal(test, exitOK, exitCode)
+	assert.Equal(test, "30.48 cm\n", stdout)
+
+	exitCode, stdout, _ = runCLI("", "validate")
+	assert.Equal(test, exitOK, exitCode)
+	assert.Equal(test, "the default unit library is valid\n", stdout)
+
+	exitCode, _, stderr := runCLI("", "convert", "--no-defaults", "1", "ft", "cm")
+	assert.Equal(test, exitInvalidConfig, exitCode)
+	assert.Contains(test, stderr, "converter.yml")
+}
+
+func TestRunSelfTest(test *testing.T) {
+	exitCode, stdout, _ := runCLI("", "selftest", "--config", "converter.yml")
+	assert.Equal(test, exitOK, exitCode)
+	assert.Regexp(test, `^\d+ passed, 0 failed\n$`, stdout)
+
+	directory, err := ioutil.TempDir("", "unit-conversion")
+	assert.NoError(test, err)
+	defer os.RemoveAll(directory)
+
+	path := filepath.Join(directory, "converter.yml")
+	assert.NoError(test, ioutil.WriteFile(path, []byte(`preferredUnits:
+  - m
+conversions:
+  - from: cm
+    to: m
+    formula: magnitude / 10
+    testFixtures:
+      - input: 100
+        expected: 1
+`), 0644))
+
+	exitCode, stdout, _ = runCLI("", "selftest", "--no-defaults", "--config", path)
+	assert.Equal(test, exitInvalidConfig, exitCode)
+	assert.Equal(test, "FAIL cm -> m: magnitude / 10\n    input 100: expected 1 but got 10, off by 9\n0 passed, 1 failed\n", stdout)
+
+	exitCode, stdout, _ = runCLI("", "selftest", "--json", "--no-defaults", "--config", path)
+	assert.Equal(test, exitInvalidConfig, exitCode)
+	assert.Contains(test, stdout, `"excluded": 1`)
+}
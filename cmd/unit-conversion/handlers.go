@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo"
+	unitconversion "github.com/tirithen/unit-conversion"
+)
+
+// conversionRequest is a single quantity to convert, To is optional and defaults to the preferred unit
+type conversionRequest struct {
+	Value *float64 `json:"value"`
+	From  string   `json:"from"`
+	To    string   `json:"to"`
+}
+
+// errorResponse is returned when a request fails as a whole
+type errorResponse struct {
+	Errors []*unitconversion.QuantityError `json:"errors"`
+}
+
+func requestError(context echo.Context, status int, quantityError *unitconversion.QuantityError) error {
+	return context.JSON(status, errorResponse{Errors: []*unitconversion.QuantityError{quantityError}})
+}
+
+// errorStatus is the HTTP status for an error from the converter, each kind of failure has its own status so that clients can tell them apart without reading the code. A unit mismatch means that a conversion step handed on a quantity in the wrong unit, which is reported as a bad response from that step, and other errors are internal errors
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, unitconversion.ErrUnitMismatch):
+		return http.StatusBadGateway
+	case errors.Is(err, unitconversion.ErrUnknownUnit):
+		return http.StatusBadRequest
+	case errors.Is(err, unitconversion.ErrNoPreferredUnit):
+		return http.StatusNotFound
+	case errors.Is(err, unitconversion.ErrNoPath):
+		return http.StatusConflict
+	case errors.Is(err, unitconversion.ErrFormula):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, unitconversion.ErrFixtureFailed):
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusInternalServerError
+}
+
+func readConversionRequest(context echo.Context) (request conversionRequest, err error) {
+	if context.Request().Method == http.MethodGet {
+		value, parseErr := strconv.ParseFloat(context.QueryParam("value"), 64)
+		if parseErr != nil {
+			err = fmt.Errorf("The query parameter value must be a number, got %q", context.QueryParam("value"))
+			return
+		}
+
+		request = conversionRequest{Value: &value, From: context.QueryParam("from"), To: context.QueryParam("to")}
+	} else {
+		contentType := context.Request().Header.Get("Content-Type")
+		if contentType != "application/json" {
+			err = fmt.Errorf("There are currently no support for Content-Type: %s , currently application/json is supported", contentType)
+			return
+		}
+
+		err = json.NewDecoder(context.Request().Body).Decode(&request)
+		if err != nil {
+			return
+		}
+	}
+
+	if request.Value == nil {
+		err = fmt.Errorf("A value to convert is required")
+	} else if math.IsNaN(*request.Value) || math.IsInf(*request.Value, 0) {
+		err = fmt.Errorf("The value to convert must be a finite number, got %v", *request.Value)
+	} else if request.From == "" {
+		err = fmt.Errorf("A unit to convert from is required")
+	}
+
+	return
+}
+
+// convertHandler converts a single quantity given either as query parameters (GET) or as a JSON body (POST)
+func convertHandler(context echo.Context) error {
+	converter := currentConverter()
+	request, err := readConversionRequest(context)
+	if err != nil {
+		return requestError(context, http.StatusBadRequest, unitconversion.NewQuantityError("", request.From, unitconversion.ErrorCodeInvalidRequest, err))
+	}
+
+	convert := converter.ConvertQuantity
+	if context.QueryParam("explain") == "true" {
+		convert = converter.ConvertExplained
+	}
+
+	result, err := convert(unitconversion.Quantity{Magnitude: *request.Value, Unit: request.From}, request.To)
+	if err != nil {
+		return requestError(context, errorStatus(err), unitconversion.NewQuantityError("", request.From, unitconversion.ErrorCodeOf(err), err))
+	}
+
+	return context.JSON(http.StatusOK, result)
+}
+
+// maxBatchSize is the largest number of jobs accepted by batchConvertHandler in one request
+const maxBatchSize = 100000
+
+// batchJobRequest is one job in a batch, ID is any JSON value chosen by the client and is returned unmodified with the result
+type batchJobRequest struct {
+	ID json.RawMessage `json:"id,omitempty"`
+	conversionRequest
+}
+
+// batchJobResponse holds either the result or the error for one job in a batch
+type batchJobResponse struct {
+	ID json.RawMessage `json:"id,omitempty"`
+	*unitconversion.ConversionResult
+	Error *unitconversion.QuantityError `json:"error,omitempty"`
+}
+
+// batchResponse is returned by batchConvertHandler with one entry per job in the same order as the request
+type batchResponse struct {
+	Results []batchJobResponse `json:"results"`
+}
+
+// errBatchTooLarge is returned by readBatchJobs when a batch has more than maxBatchSize jobs
+var errBatchTooLarge = fmt.Errorf("A batch can have at most %d jobs", maxBatchSize)
+
+// readBatchJobs reads the JSON array of jobs one job at a time so that a batch with too many jobs is rejected before it is read as a whole, the jobs are decoded by the caller so that a malformed job only fails itself
+func readBatchJobs(body io.Reader) (jobs []json.RawMessage, err error) {
+	decoder := json.NewDecoder(body)
+	token, err := decoder.Token()
+	if err != nil {
+		return
+	}
+
+	if delimiter, ok := token.(json.Delim); !ok || delimiter != '[' {
+		err = fmt.Errorf("Expected a JSON array of jobs")
+		return
+	}
+
+	jobs = []json.RawMessage{}
+	for decoder.More() {
+		if len(jobs) == maxBatchSize {
+			err = errBatchTooLarge
+			return
+		}
+
+		var job json.RawMessage
+		if err = decoder.Decode(&job); err != nil {
+			return
+		}
+		jobs = append(jobs, job)
+	}
+
+	_, err = decoder.Token()
+
+	return
+}
+
+// batchConvertHandler converts a JSON array of jobs on the form {"id": ..., "value": 10, "from": "in", "to": "cm"}, a failing job does not fail the request
+func batchConvertHandler(context echo.Context) error {
+	converter := currentConverter()
+	contentType := context.Request().Header.Get("Content-Type")
+	if contentType != "application/json" {
+		err := fmt.Errorf("There are currently no support for Content-Type: %s , currently application/json is supported", contentType)
+		return requestError(context, http.StatusUnsupportedMediaType, unitconversion.NewQuantityError("", "", unitconversion.ErrorCodeInvalidRequest, err))
+	}
+
+	rawRequests, err := readBatchJobs(context.Request().Body)
+	if err != nil {
+		if errors.Is(err, errBatchTooLarge) {
+			return requestError(context, http.StatusRequestEntityTooLarge, unitconversion.NewQuantityError("", "", unitconversion.ErrorCodeInvalidRequest, err))
+		}
+
+		return requestError(context, http.StatusBadRequest, unitconversion.NewQuantityError("", "", unitconversion.ErrorCodeInvalidJSON, err))
+	}
+
+	explain := context.QueryParam("explain") == "true"
+	jobs := []unitconversion.ConversionJob{}
+	jobIndexes := []int{}
+	requests := make([]batchJobRequest, len(rawRequests))
+	response := batchResponse{Results: make([]batchJobResponse, len(rawRequests))}
+	for index, rawRequest := range rawRequests {
+		path := strconv.Itoa(index)
+
+		// A job that does not match the format fails on its own, its id is still returned if it can be read
+		request := &requests[index]
+		if decodeErr := json.Unmarshal(rawRequest, request); decodeErr != nil {
+			var identified struct {
+				ID json.RawMessage `json:"id"`
+			}
+			json.Unmarshal(rawRequest, &identified)
+			response.Results[index].ID = identified.ID
+			response.Results[index].Error = unitconversion.NewQuantityError(path, "", unitconversion.ErrorCodeInvalidRequest, fmt.Errorf("The job is not on the form {\"value\": 10, \"from\": \"in\", \"to\": \"cm\"}: %v", decodeErr))
+			continue
+		}
+
+		response.Results[index].ID = request.ID
+		if request.Value == nil {
+			response.Results[index].Error = unitconversion.NewQuantityError(path, request.From, unitconversion.ErrorCodeInvalidRequest, fmt.Errorf("A value to convert is required"))
+		} else if request.From == "" {
+			response.Results[index].Error = unitconversion.NewQuantityError(path, request.From, unitconversion.ErrorCodeInvalidRequest, fmt.Errorf("A unit to convert from is required"))
+		} else {
+			jobs = append(jobs, unitconversion.ConversionJob{Input: unitconversion.Quantity{Magnitude: *request.Value, Unit: request.From}, To: request.To, Explain: explain})
+			jobIndexes = append(jobIndexes, index)
+		}
+	}
+
+	for jobIndex, result := range converter.ConvertBatch(jobs) {
+		index := jobIndexes[jobIndex]
+		if result.Err != nil {
+			response.Results[index].Error = unitconversion.NewQuantityError(strconv.Itoa(index), requests[index].From, unitconversion.ErrorCodeOf(result.Err), result.Err)
+			continue
+		}
+
+		conversionResult := result.ConversionResult
+		response.Results[index].ConversionResult = &conversionResult
+	}
+
+	return context.JSON(http.StatusOK, response)
+}
+
+// unitsResponse is returned by unitsHandler
+type unitsResponse struct {
+	Units []unitconversion.UnitDefinition `json:"units"`
+}
+
+// reachableUnitsResponse is returned by reachableUnitsHandler
+type reachableUnitsResponse struct {
+	Unit      string   `json:"unit"`
+	Reachable []string `json:"reachable"`
+}
+
+// preferredUnitsResponse is returned by preferredUnitsHandler
+type preferredUnitsResponse struct {
+	PreferredUnits []string `json:"preferredUnits"`
+}
+
+// unitsHandler lists the known units, the query parameter q limits the list to units where the name or an alias starts with q
+func unitsHandler(context echo.Context) error {
+	return context.JSON(http.StatusOK, unitsResponse{Units: currentConverter().SearchUnits(context.QueryParam("q"))})
+}
+
+// reachableUnitsHandler lists the units that the unit in the query parameter from can be converted to
+func reachableUnitsHandler(context echo.Context) error {
+	converter := currentConverter()
+	from := context.QueryParam("from")
+	units, err := converter.ReachableUnits(from)
+	if err != nil {
+		return requestError(context, errorStatus(err), unitconversion.NewQuantityError("", from, unitconversion.ErrorCodeOf(err), err))
+	}
+
+	return context.JSON(http.StatusOK, reachableUnitsResponse{Unit: converter.ResolveUnit(from), Reachable: units})
+}
+
+// preferredUnitsHandler lists the units that quantities are converted to when no unit is given
+func preferredUnitsHandler(context echo.Context) error {
+	preferredUnits := append([]string{}, currentConverter().PreferredUnits...)
+
+	return context.JSON(http.StatusOK, preferredUnitsResponse{PreferredUnits: preferredUnits})
+}
+
+// graphHandler exports the conversion graph, the query parameter format is either dot (default) or mermaid
+func graphHandler(context echo.Context) error {
+	format := unitconversion.GraphFormat(context.QueryParam("format"))
+	if format == "" {
+		format = unitconversion.GraphFormatDOT
+	}
+
+	graph, err := currentConverter().Graph(format)
+	if err != nil {
+		return requestError(context, http.StatusBadRequest, unitconversion.NewQuantityError("", "", unitconversion.ErrorCodeInvalidRequest, err))
+	}
+
+	contentType := "text/vnd.graphviz; charset=utf-8"
+	if format == unitconversion.GraphFormatMermaid {
+		contentType = "text/vnd.mermaid; charset=utf-8"
+	}
+
+	return context.Blob(http.StatusOK, contentType, []byte(graph))
+}
+
+// selfTestHandler runs the test fixtures of every conversion in the current configuration, including the conversions that were excluded in degraded mode
+func selfTestHandler(context echo.Context) error {
+	report := currentConverter().TestReport()
+	if !report.OK() {
+		return context.JSON(http.StatusServiceUnavailable, report)
+	}
+
+	return context.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	unitconversion "github.com/tirithen/unit-conversion"
+)
+
+func TestConvertHandlerWithQuery(test *testing.T) {
+	loadTestConverter(test)
+
+	recorder := performRequest(convertHandler, http.MethodGet, "/convert?value=10&from=in&to=cm", "")
+
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.JSONEq(test, `{"magnitude": 25.4, "unit": "cm", "path": ["in", "m", "cm"], "warnings": []}`, recorder.Body.String())
+}
+
+func TestConvertHandlerWithJSON(test *testing.T) {
+	loadTestConverter(test)
+
+	recorder := performRequest(convertHandler, http.MethodPost, "/convert", `{"value": 10, "from": "[in_i]", "to": "cm"}`)
+
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.JSONEq(test, `{
+		"magnitude": 25.4,
+		"unit": "cm",
+		"path": ["in", "m", "cm"],
+		"warnings": ["The unit \"[in_i]\" was interpreted as \"in\""]
+	}`, recorder.Body.String())
+}
+
+func TestConvertHandlerWithPreferredUnit(test *testing.T) {
+	loadTestConverter(test)
+
+	recorder := performRequest(convertHandler, http.MethodGet, "/convert?value=1500&from=mg", "")
+
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.JSONEq(test, `{
+		"magnitude": 1.5,
+		"unit": "g",
+		"path": ["mg", "g"],
+		"warnings": ["No unit to convert to was given, the preferred unit \"g\" was used"]
+	}`, recorder.Body.String())
+}
+
+func TestFailConvertHandlerWithBadRequests(test *testing.T) {
+	loadTestConverter(test)
+
+	targets := []string{"/convert?value=ten&from=in&to=cm", "/convert?from=in&to=cm", "/convert?value=10&to=cm", "/convert?value=Inf&from=in&to=cm", "/convert?value=-Inf&from=in&to=cm", "/convert?value=NaN&from=in&to=cm"}
+	for _, target := range targets {
+		recorder := performRequest(convertHandler, http.MethodGet, target, "")
+		assert.Equal(test, http.StatusBadRequest, recorder.Code, target)
+		assert.Contains(test, recorder.Body.String(), `"code":"invalid_request"`, target)
+	}
+
+	recorder := performRequest(convertHandler, http.MethodPost, "/convert", `{"value": "10", "from": "in"}`)
+	assert.Equal(test, http.StatusBadRequest, recorder.Code)
+}
+
+func TestFailConvertHandlerWithNonFiniteResult(test *testing.T) {
+	loadTestConverter(test)
+
+	recorder := performRequest(convertHandler, http.MethodGet, "/convert?value=1e308&from=m&to=cm", "")
+	assert.Equal(test, http.StatusUnprocessableEntity, recorder.Code)
+	assert.Contains(test, recorder.Body.String(), `"code":"formula_failed"`)
+	assert.Contains(test, recorder.Body.String(), "is not a finite number")
+}
+
+func TestFailConvertHandlerWithMissingConversion(test *testing.T) {
+	loadTestConverter(test)
+
+	recorder := performRequest(convertHandler, http.MethodGet, "/convert?value=10&from=in&to=kg", "")
+	assert.Equal(test, http.StatusConflict, recorder.Code)
+	assert.Contains(test, recorder.Body.String(), `"code":"no_path"`)
+
+	recorder = performRequest(convertHandler, http.MethodGet, "/convert?value=10&from=parsec", "")
+	assert.Equal(test, http.StatusNotFound, recorder.Code)
+	assert.Contains(test, recorder.Body.String(), `"code":"no_preferred_unit"`)
+
+	recorder = performRequest(convertHandler, http.MethodGet, "/convert?value=10&from=parsec&to=m", "")
+	assert.Equal(test, http.StatusBadRequest, recorder.Code)
+	assert.Contains(test, recorder.Body.String(), `"code":"unknown_unit"`)
+}
+
+func TestErrorStatus(test *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+	}{
+		{&unitconversion.UnknownUnitError{Unit: "parsec"}, http.StatusBadRequest},
+		{&unitconversion.NoPreferredUnitError{Unit: "parsec"}, http.StatusNotFound},
+		{&unitconversion.NoPathError{From: "in", To: "kg"}, http.StatusConflict},
+		{&unitconversion.FormulaError{Formula: "magnitude +", Err: fmt.Errorf("Unexpected end of expression")}, http.StatusUnprocessableEntity},
+		{&unitconversion.UnitMismatchError{Expected: "m", Actual: "in"}, http.StatusBadGateway},
+		{&unitconversion.FixtureError{From: "m", To: "cm"}, http.StatusServiceUnavailable},
+		{fmt.Errorf("The converter failed"), http.StatusInternalServerError},
+	}
+
+	statuses := map[int]error{}
+	for _, testCase := range cases {
+		assert.Equal(test, testCase.status, errorStatus(testCase.err), testCase.err.Error())
+		assert.NotContains(test, statuses, testCase.status, testCase.err.Error())
+		statuses[testCase.status] = testCase.err
+	}
+
+	for _, sentinel := range []error{unitconversion.ErrUnknownUnit, unitconversion.ErrNoPath, unitconversion.ErrNoPreferredUnit, unitconversion.ErrUnitMismatch, unitconversion.ErrFormula, unitconversion.ErrFixtureFailed} {
+		assert.Equal(test, errorStatus(fmt.Errorf("wrapped: %w", sentinel)), errorStatus(sentinel), sentinel.Error())
+	}
+}
+
+func TestBatchConvertHandler(test *testing.T) {
+	loadTestConverter(test)
+
+	recorder := performRequest(batchConvertHandler, http.MethodPost, "/convert/batch", `[
+		{"id": "a", "value": 10, "from": "in", "to": "cm"},
+		{"id": 2, "value": 10, "from": "in", "to": "kg"},
+		{"value": 20, "from": "in", "to": "cm"},
+		{"id": "d", "from": "in", "to": "cm"}
+	]`)
+
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.JSONEq(test, `{"results": [
+		{"id": "a", "magnitude": 25.4, "unit": "cm", "path": ["in", "m", "cm"], "warnings": []},
+		{"id": 2, "error": {"path": "1", "unit": "in", "code": "no_path", "message": "Unable to find a path from \"in\" to \"kg\""}},
+		{"magnitude": 50.8, "unit": "cm", "path": ["in", "m", "cm"], "warnings": []},
+		{"id": "d", "error": {"path": "3", "unit": "in", "code": "invalid_request", "message": "A value to convert is required"}}
+	]}`, recorder.Body.String())
+}
+
+func TestBatchConvertHandlerWithNonFiniteResult(test *testing.T) {
+	loadTestConverter(test)
+
+	recorder := performRequest(batchConvertHandler, http.MethodPost, "/convert/batch", `[{"value": 1e308, "from": "m", "to": "cm"}, {"value": 1, "from": "in", "to": "cm"}]`)
+	assert.Equal(test, http.StatusOK, recorder.Code)
+
+	var response struct {
+		Results []struct {
+			Magnitude float64 `json:"magnitude"`
+			Error     *struct {
+				Path string `json:"path"`
+				Code string `json:"code"`
+			} `json:"error"`
+		} `json:"results"`
+	}
+	assert.NoError(test, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Len(test, response.Results, 2)
+	assert.Equal(test, "0", response.Results[0].Error.Path)
+	assert.Equal(test, unitconversion.ErrorCodeFormulaFailed, response.Results[0].Error.Code)
+	assert.Nil(test, response.Results[1].Error)
+	assert.Equal(test, 2.54, response.Results[1].Magnitude)
+}
+
+func TestBatchConvertHandlerWithMalformedJob(test *testing.T) {
+	loadTestConverter(test)
+
+	recorder := performRequest(batchConvertHandler, http.MethodPost, "/convert/batch", `[
+		{"value": 1, "from": "inch", "to": "cm"},
+		{"id": "b", "value": "10", "from": "inch", "to": "cm"},
+		"10 inch"
+	]`)
+
+	assert.Equal(test, http.StatusOK, recorder.Code)
+
+	var response struct {
+		Results []struct {
+			ID        json.RawMessage `json:"id"`
+			Magnitude float64         `json:"magnitude"`
+			Error     *struct {
+				Path string `json:"path"`
+				Code string `json:"code"`
+			} `json:"error"`
+		} `json:"results"`
+	}
+	assert.NoError(test, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Len(test, response.Results, 3)
+	assert.Equal(test, 2.54, response.Results[0].Magnitude)
+	assert.Nil(test, response.Results[0].Error)
+	assert.Equal(test, json.RawMessage(`"b"`), response.Results[1].ID)
+	assert.Equal(test, "1", response.Results[1].Error.Path)
+	assert.Equal(test, unitconversion.ErrorCodeInvalidRequest, response.Results[1].Error.Code)
+	assert.Equal(test, "2", response.Results[2].Error.Path)
+	assert.Equal(test, unitconversion.ErrorCodeInvalidRequest, response.Results[2].Error.Code)
+}
+
+func TestFailBatchConvertHandlerWithTooManyJobs(test *testing.T) {
+	loadTestConverter(test)
+
+	body := "[" + strings.Repeat(`{"value": 1, "from": "in"},`, maxBatchSize) + `{"value": 1, "from": "in"}]`
+	recorder := performRequest(batchConvertHandler, http.MethodPost, "/convert/batch", body)
+
+	assert.Equal(test, http.StatusRequestEntityTooLarge, recorder.Code)
+	assert.Contains(test, recorder.Body.String(), `"code":"invalid_request"`)
+}
+
+func TestFailBatchConvertHandlerWithBadSyntax(test *testing.T) {
+	loadTestConverter(test)
+
+	recorder := performRequest(batchConvertHandler, http.MethodPost, "/convert/batch", `{"value": 10, "from": "in"}`)
+
+	assert.Equal(test, http.StatusBadRequest, recorder.Code)
+	assert.Contains(test, recorder.Body.String(), `"code":"invalid_json"`)
+}
+
+func TestUnitsHandler(test *testing.T) {
+	loadTestConverter(test)
+
+	recorder := performRequest(unitsHandler, http.MethodGet, "/units?q=inch", "")
+
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.JSONEq(test, `{"units": [{"name": "in", "dimension": "length", "aliases": ["inch", "[in_i]"]}]}`, recorder.Body.String())
+}
+
+func TestReachableUnitsHandler(test *testing.T) {
+	loadTestConverter(test)
+
+	recorder := performRequest(reachableUnitsHandler, http.MethodGet, "/units/reachable?from=ng/mL", "")
+
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.JSONEq(test, `{"unit": "ng/ml", "reachable": ["µg/l"]}`, recorder.Body.String())
+
+	recorder = performRequest(reachableUnitsHandler, http.MethodGet, "/units/reachable?from=parsec", "")
+	assert.Equal(test, http.StatusBadRequest, recorder.Code)
+}
+
+func TestPreferredUnitsHandler(test *testing.T) {
+	loadTestConverter(test)
+
+	recorder := performRequest(preferredUnitsHandler, http.MethodGet, "/preferred-units", "")
+
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.JSONEq(test, `{"preferredUnits": ["m", "l", "g", "µg/l"]}`, recorder.Body.String())
+}
+
+func TestConvertHandlerWithExplain(test *testing.T) {
+	loadTestConverter(test)
+
+	recorder := performRequest(convertHandler, http.MethodGet, "/convert?value=10&from=in&to=cm&explain=true", "")
+
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.JSONEq(test, `{
+		"magnitude": 25.4,
+		"unit": "cm",
+		"path": ["in", "m", "cm"],
+		"warnings": [],
+		"explanation": {
+			"hops": [
+				{"from": "in", "to": "m", "formula": "magnitude * 0.0254", "magnitude": 0.254},
+				{"from": "m", "to": "cm", "formula": "magnitude * 100", "magnitude": 25.4}
+			],
+			"cached": false
+		}
+	}`, recorder.Body.String())
+}
+
+func TestGraphHandler(test *testing.T) {
+	loadTestConverter(test)
+
+	recorder := performRequest(graphHandler, http.MethodGet, "/graph", "")
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.Contains(test, recorder.Header().Get("Content-Type"), "text/vnd.graphviz")
+	assert.Contains(test, recorder.Body.String(), `"in" -> "m" [label="magnitude * 0.0254"];`)
+
+	recorder = performRequest(graphHandler, http.MethodGet, "/graph?format=mermaid", "")
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.Contains(test, recorder.Body.String(), "flowchart LR")
+
+	recorder = performRequest(graphHandler, http.MethodGet, "/graph?format=svg", "")
+	assert.Equal(test, http.StatusBadRequest, recorder.Code)
+}
+
+func TestSelfTestHandler(test *testing.T) {
+	loadTestConverter(test)
+
+	recorder := performRequest(selfTestHandler, http.MethodGet, "/selftest", "")
+
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.Contains(test, recorder.Body.String(), `"failed":0`)
+}
@@ -0,0 +1,226 @@
+// Command unit-conversion is the HTTP service and command line interface for the unit-conversion package
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/labstack/echo"
+	unitconversion "github.com/tirithen/unit-conversion"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// serve runs the HTTP service with the configuration in configPaths loaded according to options until it fails
+func serve(configPaths []string, options unitconversion.LoadOptions, address string, stderr io.Writer) int {
+	server := echo.New()
+
+	reloader := newConfigReloader(options, configPaths...)
+	err := reloader.reload()
+	if err != nil {
+		name := strings.Join(configPaths, ", ")
+		if name == "" {
+			name = "the default unit library"
+		}
+
+		fmt.Fprintf(stderr, "Unable to load %s: %v\n", name, err)
+		return exitInvalidConfig
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go reloader.watch(server.Logger, signals, configPollInterval, nil)
+
+	registerRoutes(server, reloader)
+
+	server.Logger.Error(server.Start(address))
+
+	return exitFailed
+}
+
+// registerRoutes adds every endpoint of the service to server, each route is described in openapi.go
+func registerRoutes(server *echo.Echo, reloader *configReloader) {
+	server.GET("/", getHandler)
+	server.POST("/", postHandler)
+	server.POST("/revert", revertHandler)
+	server.GET("/convert", convertHandler)
+	server.POST("/convert", convertHandler)
+	server.POST("/convert/batch", batchConvertHandler)
+	server.GET("/units", unitsHandler)
+	server.GET("/units/reachable", reachableUnitsHandler)
+	server.GET("/preferred-units", preferredUnitsHandler)
+	server.GET("/graph", graphHandler)
+	server.GET("/openapi.json", openAPIHandler)
+	server.GET("/status", reloader.statusHandler)
+	server.GET("/selftest", selfTestHandler)
+}
+
+// documentResponse is returned by the endpoints that converts whole JSON documents
+type documentResponse struct {
+	Document json.RawMessage                 `json:"document,omitempty"`
+	Errors   []*unitconversion.QuantityError `json:"errors"`
+}
+
+func quantityErrors(errors []error) (quantityErrors []*unitconversion.QuantityError) {
+	quantityErrors = []*unitconversion.QuantityError{}
+	for _, err := range errors {
+		quantityError, ok := err.(*unitconversion.QuantityError)
+		if !ok {
+			quantityError = unitconversion.NewQuantityError("", "", unitconversion.ErrorCodeOf(err), err)
+		}
+
+		quantityErrors = append(quantityErrors, quantityError)
+	}
+
+	return
+}
+
+// handleDocument runs transform on a JSON request body, errors for single quantities are returned next to the document unless the request has strict=true
+func handleDocument(context echo.Context, transform func(input string) (string, []error)) error {
+	contentType := context.Request().Header.Get("Content-Type")
+
+	if contentType != "application/json" {
+		return context.String(
+			http.StatusUnsupportedMediaType,
+			"There are currently no support for Content-Type: "+contentType+" , currently application/json is supported.",
+		)
+	}
+
+	body, err := ioutil.ReadAll(context.Request().Body)
+	if err != nil {
+		context.Logger().Error(err)
+		return context.String(http.StatusBadRequest, "Bad Request")
+	}
+
+	output, errors := transform(string(body))
+	response := documentResponse{Errors: quantityErrors(errors)}
+	if len(errors) > 0 {
+		context.Logger().Warn(errors)
+	}
+
+	for _, quantityError := range response.Errors {
+		if quantityError.Code == unitconversion.ErrorCodeInvalidJSON {
+			return context.JSON(http.StatusBadRequest, response)
+		}
+	}
+
+	if len(errors) > 0 && context.QueryParam("strict") == "true" {
+		return context.JSON(http.StatusUnprocessableEntity, response)
+	}
+
+	response.Document = json.RawMessage(output)
+
+	return context.JSON(http.StatusOK, response)
+}
+
+func postHandler(context echo.Context) error {
+	requestConverter := *currentConverter()
+	requestConverter.Annotate = context.QueryParam("annotate") == "true"
+	requestConverter.Explain = context.QueryParam("explain") == "true"
+	requestConverter.AcceptStringMagnitudes = context.QueryParam("stringMagnitudes") == "true"
+
+	return handleDocument(context, requestConverter.ConvertToPreferredUnits)
+}
+
+func revertHandler(context echo.Context) error {
+	return handleDocument(context, currentConverter().RevertAnnotations)
+}
+
+func getHandler(context echo.Context) error {
+	return context.String(
+		http.StatusMethodNotAllowed,
+		`
+Make a POST request to this URL with Content-Type: application/json and a body that contains objects with the properties magnitude and unit. e.g.:
+
+    $ curl -d '{"model":"Supertablet","size":{"magnitude":10, "unit":"in"}}' -H "Content-Type: application/json" -X POST http://localhost:8080/
+
+Or use your favorite HTTP tool.
+
+The response has the converted JSON under "document" and an "errors" array with one entry per quantity that could not be converted, each entry has the JSON "path", the "unit", a machine readable "code" and a "message". Quantities that fails are left unmodified in the document. Add ?strict=true to the URL to instead fail the whole request with 422 Unprocessable Entity if any quantity fails.
+
+Add ?annotate=true to the URL to keep the original magnitude and unit together with the conversion steps in each converted object, POST the annotated output to /revert to get the original values back. Add ?explain=true instead to also get the magnitude after each conversion step.
+
+Magnitudes written as strings, e.g. "magnitude": "10.5", are only converted when ?stringMagnitudes=true is added to the URL, they are then written back as strings.
+
+To convert a single quantity make a GET request to /convert with the query parameters value, from and to, e.g.:
+
+    $ curl 'http://localhost:8080/convert?value=10&from=in&to=cm'
+
+Or POST the same as JSON, e.g. {"value": 10, "from": "in", "to": "cm"}. The response has the converted magnitude and unit, the units that the conversion went through and any warnings. If to is left out the quantity is converted to its preferred unit. Add explain=true to the query to also get each conversion step with its formula and the magnitude after the step.
+
+To convert many quantities in one request POST a JSON array of jobs to /convert/batch, e.g.:
+
+    $ curl -d '[{"id": 1, "value": 10, "from": "in", "to": "cm"}, {"id": 2, "value": 5, "from": "lb"}]' -H "Content-Type: application/json" -X POST http://localhost:8080/convert/batch
+
+The response has a "results" array in the same order as the jobs, each entry has the optional "id" of the job and either the same properties as a /convert response or an "error". A job that fails does not fail the other jobs. Add ?explain=true to the URL to get the conversion steps for every job.
+
+To find out which units that can be converted use:
+
+    GET /units                  lists all units with their dimension and aliases, add ?q=prefix to search by name or alias
+    GET /units/reachable?from=m lists the units that m can be converted to
+    GET /preferred-units        lists the units that quantities are converted to by default
+    GET /graph?format=dot       exports all conversions as a Graphviz DOT graph, or use format=mermaid for a Mermaid flowchart
+
+Units and conversions come from the built in default unit library with the configuration in converter.yml on top of it, when there is one. The configuration is reloaded when the file changes or when the process receives SIGHUP, a configuration that fails its tests is not used. GET /status shows when the configuration was loaded and the error of the latest reload, if any.
+
+GET /selftest runs the test fixtures of every conversion and lists the result of each of them, it answers 503 Service Unavailable if any conversion fails. Start the service with --degraded to leave conversions that fail their tests out instead of refusing to start, they are then listed by /selftest as excluded.
+
+The full API is described by the OpenAPI document at /openapi.json.
+
+Some more examples of data structures
+
+    {
+      "demographic": {
+        "name": "Lei"
+      },
+      "measurements": {
+        "height": {
+          "magnitude": 5,
+          "unit": "ft",
+          "extraproperty": "will be kept without modification"
+        },
+        "weight": [
+          {
+            "magnitude": 154.323584,
+            "unit": "lb"
+          },
+          {
+            "magnitude": 71.6,
+            "unit": "kg"
+          }
+        ]
+      }
+    }
+
+Or just lots of values in an array/object or similar. e.g.:
+
+    [
+      {
+        "magnitude": 5,
+        "unit": "ft"
+      },
+      {
+        "magnitude": 5,
+        "unit": "cm"
+      },
+      {
+        "magnitude": 5,
+        "unit": "ml"
+      },
+      {
+        "magnitude": 5,
+        "unit": "oz"
+      }
+    ]
+    `,
+	)
+}
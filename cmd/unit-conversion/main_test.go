@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	unitconversion "github.com/tirithen/unit-conversion"
+)
+
+// TestMain runs the tests from the root of the repository, where the commands find the example converter.yml as they do when the binary is started there
+func TestMain(m *testing.M) {
+	if err := os.Chdir("../.."); err != nil {
+		panic(err)
+	}
+
+	os.Exit(m.Run())
+}
+
+func loadTestConverter(test *testing.T) {
+	converterConfig, err := ioutil.ReadFile("converter.yml")
+	assert.NoError(test, err)
+
+	converter, err := unitconversion.NewJSONConverterFromYAML(converterConfig)
+	assert.NoError(test, err)
+	setConverter(&converter)
+}
+
+func performRequest(handler echo.HandlerFunc, method string, target string, body string) *httptest.ResponseRecorder {
+	server := echo.New()
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+
+	handler(server.NewContext(request, recorder))
+
+	return recorder
+}
+
+func TestPostHandler(test *testing.T) {
+	loadTestConverter(test)
+
+	recorder := performRequest(postHandler, http.MethodPost, "/", `{"size": {"magnitude": 10, "unit": "cm"}}`)
+
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.JSONEq(test, `{"document": {"size": {"magnitude": 0.1, "unit": "m"}}, "errors": []}`, recorder.Body.String())
+}
+
+func TestPostHandlerWithPartialSuccess(test *testing.T) {
+	loadTestConverter(test)
+
+	recorder := performRequest(postHandler, http.MethodPost, "/", `{"size": {"magnitude": 10, "unit": "cm"}, "items": [{"magnitude": 1, "unit": "parsec"}]}`)
+
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.JSONEq(test, `{
+		"document": {"size": {"magnitude": 0.1, "unit": "m"}, "items": [{"magnitude": 1, "unit": "parsec"}]},
+		"errors": [{
+			"path": "items.0",
+			"unit": "parsec",
+			"code": "no_preferred_unit",
+			"message": "Unable to find a preferred unit for \"parsec\", conversion not possible"
+		}]
+	}`, recorder.Body.String())
+}
+
+func TestFailPostHandlerInStrictMode(test *testing.T) {
+	loadTestConverter(test)
+
+	recorder := performRequest(postHandler, http.MethodPost, "/?strict=true", `{"size": {"magnitude": 10, "unit": "cm"}, "items": [{"magnitude": 1, "unit": "parsec"}]}`)
+
+	assert.Equal(test, http.StatusUnprocessableEntity, recorder.Code)
+	assert.NotContains(test, recorder.Body.String(), "document")
+	assert.Contains(test, recorder.Body.String(), `"code":"no_preferred_unit"`)
+}
+
+func TestFailPostHandlerWithBadSyntax(test *testing.T) {
+	loadTestConverter(test)
+
+	recorder := performRequest(postHandler, http.MethodPost, "/", `{ "test": { "magnitude": 12  "unit": "cm" } }`)
+
+	assert.Equal(test, http.StatusBadRequest, recorder.Code)
+	assert.Contains(test, recorder.Body.String(), `"code":"invalid_json"`)
+}
+
+func TestPostHandlerWithAnnotationsAndRevert(test *testing.T) {
+	loadTestConverter(test)
+
+	input := `{"size": {"magnitude": 10, "unit": "cm"}}`
+	recorder := performRequest(postHandler, http.MethodPost, "/?annotate=true", input)
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.Contains(test, recorder.Body.String(), `"original":{"magnitude":10,"unit":"cm"}`)
+
+	response := documentResponse{}
+	assert.NoError(test, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+	recorder = performRequest(revertHandler, http.MethodPost, "/revert", string(response.Document))
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.JSONEq(test, `{"document": `+input+`, "errors": []}`, recorder.Body.String())
+}
+
+func TestPostHandlerWithExplain(test *testing.T) {
+	loadTestConverter(test)
+
+	recorder := performRequest(postHandler, http.MethodPost, "/?explain=true", `{"size": {"magnitude": 10, "unit": "cm"}}`)
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.Contains(test, recorder.Body.String(), `"original":{"magnitude":10,"unit":"cm"}`)
+	assert.Contains(test, recorder.Body.String(), `"steps":[{"from":"cm","to":"m","formula":"magnitude / 100","magnitude":0.1}]`)
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/labstack/echo"
+	unitconversion "github.com/tirithen/unit-conversion"
+)
+
+// openAPIObject is a JSON object in an OpenAPI document
+type openAPIObject map[string]interface{}
+
+func openAPIRef(schema string) openAPIObject {
+	return openAPIObject{"$ref": "#/components/schemas/" + schema}
+}
+
+func openAPIJSONContent(schema openAPIObject) openAPIObject {
+	return openAPIObject{"application/json": openAPIObject{"schema": schema}}
+}
+
+func openAPIResponse(description string, schema string) openAPIObject {
+	return openAPIObject{"description": description, "content": openAPIJSONContent(openAPIRef(schema))}
+}
+
+func openAPITextResponse(description string) openAPIObject {
+	return openAPIObject{"description": description, "content": openAPIObject{"text/plain": openAPIObject{"schema": openAPIObject{"type": "string"}}}}
+}
+
+func openAPIQueryParameter(name string, description string, schema openAPIObject, required bool) openAPIObject {
+	return openAPIObject{"name": name, "in": "query", "description": description, "required": required, "schema": schema}
+}
+
+func openAPIFlagParameter(name string, description string) openAPIObject {
+	return openAPIQueryParameter(name, description, openAPIObject{"type": "boolean", "default": false}, false)
+}
+
+// openAPIUnitNames lists the names and aliases of the units known by converter, used as enum for unit parameters
+func openAPIUnitNames(converter *unitconversion.Converter) (names []string) {
+	names = []string{}
+	seen := map[string]bool{}
+	for _, unit := range converter.Units() {
+		for _, name := range append([]string{unit.Name}, unit.Aliases...) {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+
+	return
+}
+
+func openAPISchemas(converter *unitconversion.Converter) openAPIObject {
+	stringList := openAPIObject{"type": "array", "items": openAPIObject{"type": "string"}}
+
+	return openAPIObject{
+		"Unit": openAPIObject{
+			"type":        "string",
+			"description": "A unit given by its name, an alias or its UCUM code",
+			"enum":        openAPIUnitNames(converter),
+		},
+		"QuantityError": openAPIObject{
+			"type":     "object",
+			"required": []string{"path", "code", "message"},
+			"properties": openAPIObject{
+				"path":    openAPIObject{"type": "string", "description": "JSON path of the quantity, or the index of the job in a batch"},
+				"unit":    openAPIObject{"type": "string"},
+				"code":    openAPIObject{"type": "string", "enum": []string{unitconversion.ErrorCodeInvalidJSON, unitconversion.ErrorCodeNoPreferredUnit, unitconversion.ErrorCodeConversionFailed, unitconversion.ErrorCodeWriteFailed, unitconversion.ErrorCodeInvalidAnnotation, unitconversion.ErrorCodeInvalidRequest, unitconversion.ErrorCodeUnknownUnit, unitconversion.ErrorCodeNoPath, unitconversion.ErrorCodeUnitMismatch, unitconversion.ErrorCodeFormulaFailed, unitconversion.ErrorCodeFixtureFailed, unitconversion.ErrorCodePrecisionLost}},
+				"message": openAPIObject{"type": "string"},
+			},
+		},
+		"ErrorResponse": openAPIObject{
+			"type":       "object",
+			"required":   []string{"errors"},
+			"properties": openAPIObject{"errors": openAPIObject{"type": "array", "items": openAPIRef("QuantityError")}},
+		},
+		"DocumentResponse": openAPIObject{
+			"type":     "object",
+			"required": []string{"errors"},
+			"properties": openAPIObject{
+				"document": openAPIObject{"description": "The converted JSON document, left out when the request fails as a whole"},
+				"errors":   openAPIObject{"type": "array", "items": openAPIRef("QuantityError")},
+			},
+		},
+		"ConversionRequest": openAPIObject{
+			"type":     "object",
+			"required": []string{"value", "from"},
+			"properties": openAPIObject{
+				"value": openAPIObject{"type": "number"},
+				"from":  openAPIRef("Unit"),
+				"to":    openAPIRef("Unit"),
+			},
+		},
+		"ConversionResult": openAPIObject{
+			"type":     "object",
+			"required": []string{"magnitude", "unit", "path", "warnings"},
+			"properties": openAPIObject{
+				"magnitude":   openAPIObject{"type": "number"},
+				"unit":        openAPIObject{"type": "string"},
+				"path":        stringList,
+				"warnings":    stringList,
+				"explanation": openAPIRef("Explanation"),
+			},
+		},
+		"Explanation": openAPIObject{
+			"type":     "object",
+			"required": []string{"hops", "cached"},
+			"properties": openAPIObject{
+				"hops": openAPIObject{"type": "array", "items": openAPIObject{
+					"type":     "object",
+					"required": []string{"from", "to", "formula", "magnitude"},
+					"properties": openAPIObject{
+						"from":      openAPIObject{"type": "string"},
+						"to":        openAPIObject{"type": "string"},
+						"formula":   openAPIObject{"type": "string"},
+						"magnitude": openAPIObject{"type": "number"},
+					},
+				}},
+				"cached": openAPIObject{"type": "boolean"},
+			},
+		},
+		"BatchJob": openAPIObject{
+			"allOf": []interface{}{
+				openAPIRef("ConversionRequest"),
+				openAPIObject{"properties": openAPIObject{"id": openAPIObject{"description": "Any JSON value, returned unmodified with the result"}}},
+			},
+		},
+		"BatchResponse": openAPIObject{
+			"type":     "object",
+			"required": []string{"results"},
+			"properties": openAPIObject{"results": openAPIObject{"type": "array", "items": openAPIObject{
+				"allOf": []interface{}{
+					openAPIObject{"properties": openAPIObject{"id": openAPIObject{}, "error": openAPIRef("QuantityError")}},
+					openAPIObject{"oneOf": []interface{}{openAPIRef("ConversionResult"), openAPIObject{"required": []string{"error"}}}},
+				},
+			}}},
+		},
+		"UnitsResponse": openAPIObject{
+			"type":     "object",
+			"required": []string{"units"},
+			"properties": openAPIObject{"units": openAPIObject{"type": "array", "items": openAPIObject{
+				"type":     "object",
+				"required": []string{"name", "aliases"},
+				"properties": openAPIObject{
+					"name":      openAPIObject{"type": "string"},
+					"dimension": openAPIObject{"type": "string"},
+					"aliases":   stringList,
+				},
+			}}},
+		},
+		"ReachableUnitsResponse": openAPIObject{
+			"type":       "object",
+			"required":   []string{"unit", "reachable"},
+			"properties": openAPIObject{"unit": openAPIObject{"type": "string"}, "reachable": stringList},
+		},
+		"ReloadStatus": openAPIObject{
+			"type":     "object",
+			"required": []string{"configPaths", "defaults", "degraded", "files", "loadedAt", "lastAttemptAt", "units", "conversions", "excludedConversions"},
+			"properties": openAPIObject{
+				"configPaths":         stringList,
+				"defaults":            openAPIObject{"type": "boolean"},
+				"degraded":            openAPIObject{"type": "boolean", "description": "True when conversions that fail their test fixtures are left out instead of failing the reload"},
+				"files":               openAPIObject{"type": "array", "items": openAPIObject{"type": "string"}, "description": "Every file and directory that was read, including included files"},
+				"loadedAt":            openAPIObject{"type": "string", "format": "date-time"},
+				"lastAttemptAt":       openAPIObject{"type": "string", "format": "date-time"},
+				"lastError":           openAPIObject{"type": "string", "description": "Set when the latest reload failed and the previous configuration is still in use"},
+				"units":               openAPIObject{"type": "integer"},
+				"conversions":         openAPIObject{"type": "integer"},
+				"excludedConversions": openAPIObject{"type": "integer", "description": "The number of conversions that were left out in degraded mode"},
+			},
+		},
+		"FixtureResult": openAPIObject{
+			"type":     "object",
+			"required": []string{"input", "expected", "actual", "deviation", "passed"},
+			"properties": openAPIObject{
+				"input":     openAPIObject{"type": "number"},
+				"expected":  openAPIObject{"type": "number"},
+				"actual":    openAPIObject{"type": "number"},
+				"deviation": openAPIObject{"type": "number", "description": "actual - expected"},
+				"passed":    openAPIObject{"type": "boolean"},
+				"error":     openAPIObject{"type": "string", "description": "Set when the formula could not be evaluated"},
+			},
+		},
+		"ConversionTestResult": openAPIObject{
+			"type":     "object",
+			"required": []string{"from", "to", "formula", "passed", "excluded", "fixtures"},
+			"properties": openAPIObject{
+				"from":     openAPIObject{"type": "string"},
+				"to":       openAPIObject{"type": "string"},
+				"formula":  openAPIObject{"type": "string"},
+				"passed":   openAPIObject{"type": "boolean"},
+				"excluded": openAPIObject{"type": "boolean", "description": "True when the conversion was left out in degraded mode"},
+				"fixtures": openAPIObject{"type": "array", "items": openAPIRef("FixtureResult")},
+				"error":    openAPIObject{"type": "string", "description": "Set when the conversion has no test fixtures"},
+			},
+		},
+		"TestReport": openAPIObject{
+			"type":     "object",
+			"required": []string{"passed", "failed", "excluded", "conversions"},
+			"properties": openAPIObject{
+				"passed":      openAPIObject{"type": "integer"},
+				"failed":      openAPIObject{"type": "integer"},
+				"excluded":    openAPIObject{"type": "integer"},
+				"conversions": openAPIObject{"type": "array", "items": openAPIRef("ConversionTestResult")},
+			},
+		},
+		"PreferredUnitsResponse": openAPIObject{
+			"type":       "object",
+			"required":   []string{"preferredUnits"},
+			"properties": openAPIObject{"preferredUnits": stringList},
+		},
+	}
+}
+
+func openAPIPaths() openAPIObject {
+	strict := openAPIFlagParameter("strict", "Fail the whole request with 422 if any quantity cannot be converted")
+	explain := openAPIFlagParameter("explain", "Include the conversion steps in each result")
+	documentBody := openAPIObject{"required": true, "content": openAPIJSONContent(openAPIObject{"description": "Any JSON document"})}
+	documentResponses := openAPIObject{
+		"200": openAPIResponse("The converted document and the quantities that could not be converted", "DocumentResponse"),
+		"400": openAPIResponse("The body is not valid JSON", "DocumentResponse"),
+		"415": openAPITextResponse("The Content-Type is not application/json"),
+		"422": openAPIResponse("At least one quantity could not be converted and strict is true", "DocumentResponse"),
+	}
+	conversionResponses := openAPIObject{
+		"200": openAPIResponse("The converted quantity", "ConversionResult"),
+		"400": openAPIResponse("The request is missing a value or a from unit, code invalid_request, or a unit is not known, code unknown_unit", "ErrorResponse"),
+		"404": openAPIResponse("No preferred unit can be reached from the unit, code no_preferred_unit", "ErrorResponse"),
+		"409": openAPIResponse("There is no conversion path in between the units, code no_path", "ErrorResponse"),
+		"422": openAPIResponse("A conversion could not convert the magnitude, code formula_failed", "ErrorResponse"),
+		"500": openAPIResponse("The conversion failed in another way, code conversion_failed", "ErrorResponse"),
+		"502": openAPIResponse("A conversion was given a quantity with the wrong unit, code unit_mismatch", "ErrorResponse"),
+		"503": openAPIResponse("A test fixture in the configuration failed, code fixture_failed", "ErrorResponse"),
+	}
+
+	return openAPIObject{
+		"/": openAPIObject{
+			"get": openAPIObject{
+				"summary":   "Describes how to use the service",
+				"responses": openAPIObject{"405": openAPITextResponse("A description of the service")},
+			},
+			"post": openAPIObject{
+				"summary": "Converts every object with magnitude and unit, or a FHIR Quantity, in a JSON document to its preferred unit",
+				"parameters": []interface{}{
+					strict,
+					openAPIFlagParameter("annotate", "Keep the original values and the conversion steps in each converted object"),
+					openAPIFlagParameter("explain", "Work as annotate and also include the magnitude after each conversion step"),
+					openAPIFlagParameter("stringMagnitudes", "Also convert magnitudes written as JSON strings"),
+				},
+				"requestBody": documentBody,
+				"responses":   documentResponses,
+			},
+		},
+		"/revert": openAPIObject{
+			"post": openAPIObject{
+				"summary":     "Restores the original values in a document converted with annotate=true",
+				"parameters":  []interface{}{strict},
+				"requestBody": documentBody,
+				"responses":   documentResponses,
+			},
+		},
+		"/convert": openAPIObject{
+			"get": openAPIObject{
+				"summary": "Converts a single quantity",
+				"parameters": []interface{}{
+					openAPIQueryParameter("value", "The magnitude to convert", openAPIObject{"type": "number"}, true),
+					openAPIQueryParameter("from", "The unit of the value", openAPIRef("Unit"), true),
+					openAPIQueryParameter("to", "The unit to convert to, the preferred unit is used when left out", openAPIRef("Unit"), false),
+					explain,
+				},
+				"responses": conversionResponses,
+			},
+			"post": openAPIObject{
+				"summary":     "Converts a single quantity",
+				"parameters":  []interface{}{explain},
+				"requestBody": openAPIObject{"required": true, "content": openAPIJSONContent(openAPIRef("ConversionRequest"))},
+				"responses":   conversionResponses,
+			},
+		},
+		"/convert/batch": openAPIObject{
+			"post": openAPIObject{
+				"summary":     "Converts a list of quantities, a failing job does not fail the others",
+				"parameters":  []interface{}{explain},
+				"requestBody": openAPIObject{"required": true, "content": openAPIJSONContent(openAPIObject{"type": "array", "maxItems": maxBatchSize, "items": openAPIRef("BatchJob")})},
+				"responses": openAPIObject{
+					"200": openAPIResponse("One result or error per job in the same order as the jobs", "BatchResponse"),
+					"400": openAPIResponse("The body is not a JSON array of jobs", "ErrorResponse"),
+					"413": openAPIResponse("The batch has too many jobs", "ErrorResponse"),
+					"415": openAPIResponse("The Content-Type is not application/json", "ErrorResponse"),
+				},
+			},
+		},
+		"/units": openAPIObject{
+			"get": openAPIObject{
+				"summary":    "Lists the known units with their dimension and aliases",
+				"parameters": []interface{}{openAPIQueryParameter("q", "Only list units where the name or an alias starts with q, ignoring case", openAPIObject{"type": "string"}, false)},
+				"responses":  openAPIObject{"200": openAPIResponse("The units", "UnitsResponse")},
+			},
+		},
+		"/units/reachable": openAPIObject{
+			"get": openAPIObject{
+				"summary":    "Lists the units that a unit can be converted to",
+				"parameters": []interface{}{openAPIQueryParameter("from", "The unit to convert from", openAPIRef("Unit"), true)},
+				"responses": openAPIObject{
+					"200": openAPIResponse("The reachable units", "ReachableUnitsResponse"),
+					"400": openAPIResponse("The unit is not known", "ErrorResponse"),
+				},
+			},
+		},
+		"/preferred-units": openAPIObject{
+			"get": openAPIObject{
+				"summary":   "Lists the units that quantities are converted to when no unit is given",
+				"responses": openAPIObject{"200": openAPIResponse("The preferred units", "PreferredUnitsResponse")},
+			},
+		},
+		"/graph": openAPIObject{
+			"get": openAPIObject{
+				"summary":    "Exports the units and conversions as a graph with formulas as edge labels and preferred units highlighted",
+				"parameters": []interface{}{openAPIQueryParameter("format", "The graph format", openAPIObject{"type": "string", "enum": []string{string(unitconversion.GraphFormatDOT), string(unitconversion.GraphFormatMermaid)}, "default": string(unitconversion.GraphFormatDOT)}, false)},
+				"responses": openAPIObject{
+					"200": openAPIObject{"description": "The graph", "content": openAPIObject{
+						"text/vnd.graphviz": openAPIObject{"schema": openAPIObject{"type": "string"}},
+						"text/vnd.mermaid":  openAPIObject{"schema": openAPIObject{"type": "string"}},
+					}},
+					"400": openAPIResponse("The format is not known", "ErrorResponse"),
+				},
+			},
+		},
+		"/status": openAPIObject{
+			"get": openAPIObject{
+				"summary":   "Describes the configuration in use and the outcome of the latest reload",
+				"responses": openAPIObject{"200": openAPIResponse("The status of the configuration", "ReloadStatus")},
+			},
+		},
+		"/selftest": openAPIObject{
+			"get": openAPIObject{
+				"summary": "Runs the test fixtures of every conversion, including the conversions left out in degraded mode",
+				"responses": openAPIObject{
+					"200": openAPIResponse("Every conversion passed its test fixtures", "TestReport"),
+					"503": openAPIResponse("At least one conversion failed its test fixtures", "TestReport"),
+				},
+			},
+		},
+		"/openapi.json": openAPIObject{
+			"get": openAPIObject{
+				"summary":   "This OpenAPI document",
+				"responses": openAPIObject{"200": openAPIObject{"description": "The OpenAPI document", "content": openAPIJSONContent(openAPIObject{"type": "object"})}},
+			},
+		},
+	}
+}
+
+// openAPIDocument describes the HTTP service as an OpenAPI 3 document with the units known by converter
+func openAPIDocument(converter *unitconversion.Converter) openAPIObject {
+	return openAPIObject{
+		"openapi": "3.0.3",
+		"info": openAPIObject{
+			"title":       "unit-conversion",
+			"description": "Converts quantities in JSON documents in between units configured in converter.yml",
+			"version":     "1.0.0",
+		},
+		"paths":      openAPIPaths(),
+		"components": openAPIObject{"schemas": openAPISchemas(converter)},
+	}
+}
+
+// openAPIHandler serves the OpenAPI document for the currently loaded configuration
+func openAPIHandler(context echo.Context) error {
+	return context.JSON(http.StatusOK, openAPIDocument(&currentConverter().Converter))
+}
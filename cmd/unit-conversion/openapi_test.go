@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	unitconversion "github.com/tirithen/unit-conversion"
+)
+
+func TestOpenAPIDocumentHasEveryRoute(test *testing.T) {
+	loadTestConverter(test)
+
+	server := echo.New()
+	registerRoutes(server, newConfigReloader(unitconversion.LoadOptions{}, "converter.yml"))
+	paths := openAPIDocument(&currentConverter().Converter)["paths"].(openAPIObject)
+
+	registered := map[string]bool{}
+	for _, route := range server.Routes() {
+		registered[route.Method+" "+route.Path] = true
+
+		operations, found := paths[route.Path].(openAPIObject)
+		if assert.True(test, found, route.Path) {
+			assert.Contains(test, operations, strings.ToLower(route.Method), route.Method+" "+route.Path)
+		}
+	}
+
+	for path, operations := range paths {
+		for method := range operations.(openAPIObject) {
+			assert.True(test, registered[strings.ToUpper(method)+" "+path], method+" "+path)
+		}
+	}
+}
+
+func TestOpenAPIDocumentReferences(test *testing.T) {
+	loadTestConverter(test)
+
+	raw, err := json.Marshal(openAPIDocument(&currentConverter().Converter))
+	assert.NoError(test, err)
+
+	schemas := openAPISchemas(&currentConverter().Converter)
+	for _, match := range regexp.MustCompile(`"#/components/schemas/([^"]+)"`).FindAllStringSubmatch(string(raw), -1) {
+		assert.Contains(test, schemas, match[1])
+	}
+}
+
+func TestOpenAPIHandler(test *testing.T) {
+	loadTestConverter(test)
+
+	recorder := performRequest(openAPIHandler, http.MethodGet, "/openapi.json", "")
+	assert.Equal(test, http.StatusOK, recorder.Code)
+
+	document := struct {
+		OpenAPI    string `json:"openapi"`
+		Components struct {
+			Schemas struct {
+				Unit struct {
+					Enum []string `json:"enum"`
+				} `json:"Unit"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}{}
+	assert.NoError(test, json.Unmarshal(recorder.Body.Bytes(), &document))
+	assert.Equal(test, "3.0.3", document.OpenAPI)
+	assert.Contains(test, document.Components.Schemas.Unit.Enum, "µg/l")
+	assert.Contains(test, document.Components.Schemas.Unit.Enum, "ug/L")
+	assert.Contains(test, document.Components.Schemas.Unit.Enum, "inch")
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo"
+	unitconversion "github.com/tirithen/unit-conversion"
+)
+
+// configPollInterval is how often the configuration file is checked for changes
+const configPollInterval = 2 * time.Second
+
+// converterValue holds the *JSONConverter used by the handlers, it is replaced as a whole when the configuration is reloaded
+var converterValue atomic.Value
+
+// currentConverter returns the converter for the latest configuration that passed Converter.Test, handlers should call it once per request so that a request is handled with a single configuration
+func currentConverter() *unitconversion.JSONConverter {
+	return converterValue.Load().(*unitconversion.JSONConverter)
+}
+
+func setConverter(converter *unitconversion.JSONConverter) {
+	converterValue.Store(converter)
+}
+
+// ReloadStatus describes the configuration in use and the outcome of the latest attempt to load it, Files are all files and directories that were read including included files and Defaults is true when they are loaded on top of the default unit library
+type ReloadStatus struct {
+	ConfigPaths   []string  `json:"configPaths"`
+	Defaults      bool      `json:"defaults"`
+	Degraded      bool      `json:"degraded"`
+	Files         []string  `json:"files"`
+	LoadedAt      time.Time `json:"loadedAt"`
+	LastAttemptAt time.Time `json:"lastAttemptAt"`
+	LastError     string    `json:"lastError,omitempty"`
+	Units         int       `json:"units"`
+	Conversions   int       `json:"conversions"`
+	// ExcludedConversions is the number of conversions that failed their test fixtures and were left out in degraded mode, see /selftest
+	ExcludedConversions int `json:"excludedConversions"`
+}
+
+// configReloader loads the configuration files at paths into the converter used by the handlers, a configuration that fails to load leaves the previous converter in use
+type configReloader struct {
+	paths    []string
+	options  unitconversion.LoadOptions
+	mutex    sync.Mutex
+	modTimes map[string]time.Time
+	status   ReloadStatus
+}
+
+func newConfigReloader(options unitconversion.LoadOptions, paths ...string) *configReloader {
+	status := ReloadStatus{ConfigPaths: append([]string{}, paths...), Defaults: options.Defaults, Degraded: options.Degraded, Files: []string{}}
+	return &configReloader{paths: paths, options: options, status: status}
+}
+
+// modTimes returns the modification time of each file, files that can not be read are left out
+func modTimes(files []string) (times map[string]time.Time) {
+	times = map[string]time.Time{}
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			times[file] = info.ModTime()
+		}
+	}
+
+	return
+}
+
+// reload reads, parses and tests the configuration files and swaps them in if they are valid
+func (reloader *configReloader) reload() (err error) {
+	reloader.mutex.Lock()
+	defer reloader.mutex.Unlock()
+
+	reloader.status.LastAttemptAt = time.Now()
+	baseConverter, files, err := unitconversion.LoadConverterFiles(reloader.options, reloader.paths...)
+
+	// The files that were read are watched even when they failed, so that a fix is picked up
+	reloader.modTimes = modTimes(append(append([]string{}, reloader.paths...), files...))
+	if err == nil {
+		converter := unitconversion.NewJSONConverter(baseConverter)
+		setConverter(&converter)
+		reloader.status.LoadedAt = reloader.status.LastAttemptAt
+		reloader.status.Files = files
+		reloader.status.Units = len(converter.UnitNames())
+		reloader.status.Conversions = len(converter.Conversions)
+		reloader.status.ExcludedConversions = len(converter.ExcludedConversions)
+	}
+
+	reloader.status.LastError = ""
+	if err != nil {
+		reloader.status.LastError = err.Error()
+	}
+
+	return
+}
+
+// changed reports if any of the configuration files has been modified, added or removed since they were last loaded
+func (reloader *configReloader) changed() bool {
+	reloader.mutex.Lock()
+	defer reloader.mutex.Unlock()
+
+	files := []string{}
+	for file := range reloader.modTimes {
+		files = append(files, file)
+	}
+
+	current := modTimes(append(files, reloader.paths...))
+	if len(current) != len(reloader.modTimes) {
+		return true
+	}
+
+	for file, modTime := range current {
+		if !modTime.Equal(reloader.modTimes[file]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// watch reloads the configuration when a value is received on signals or when the file has changed, until stop is closed
+func (reloader *configReloader) watch(logger echo.Logger, signals <-chan os.Signal, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-signals:
+		case <-ticker.C:
+			if !reloader.changed() {
+				continue
+			}
+		}
+
+		if err := reloader.reload(); err != nil {
+			logger.Errorf("Unable to reload %s, keeping the previous configuration: %v", strings.Join(reloader.paths, ", "), err)
+		} else {
+			logger.Infof("Reloaded %s", strings.Join(reloader.paths, ", "))
+		}
+	}
+}
+
+// Status returns the outcome of the latest reload
+func (reloader *configReloader) Status() ReloadStatus {
+	reloader.mutex.Lock()
+	defer reloader.mutex.Unlock()
+
+	return reloader.status
+}
+
+// statusHandler reports which configuration is in use and whether the latest reload failed
+func (reloader *configReloader) statusHandler(context echo.Context) error {
+	return context.JSON(http.StatusOK, reloader.Status())
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	unitconversion "github.com/tirithen/unit-conversion"
+)
+
+const reloadTestConfig = `
+preferredUnits:
+  - m
+conversions:
+  - from: cm
+    to: m
+    formula: magnitude / 100
+    testFixtures:
+      - input: 100
+        expected: 1
+`
+
+func newReloadTestFile(test *testing.T, content string) (path string, cleanup func()) {
+	directory, err := ioutil.TempDir("", "unit-conversion")
+	assert.NoError(test, err)
+
+	path = filepath.Join(directory, "converter.yml")
+	assert.NoError(test, ioutil.WriteFile(path, []byte(content), 0644))
+
+	return path, func() { os.RemoveAll(directory) }
+}
+
+func TestConfigReloaderReload(test *testing.T) {
+	path, cleanup := newReloadTestFile(test, reloadTestConfig)
+	defer cleanup()
+
+	reloader := newConfigReloader(unitconversion.LoadOptions{}, path)
+	assert.NoError(test, reloader.reload())
+	assert.Equal(test, []string{"m"}, currentConverter().PreferredUnits)
+
+	status := reloader.Status()
+	assert.Equal(test, []string{path}, status.ConfigPaths)
+	assert.Equal(test, []string{path}, status.Files)
+	assert.Equal(test, 1, status.Conversions)
+	assert.Equal(test, 2, status.Units)
+	assert.Empty(test, status.LastError)
+	assert.False(test, status.LoadedAt.IsZero())
+}
+
+func TestFailConfigReloaderReloadKeepsPreviousConverter(test *testing.T) {
+	path, cleanup := newReloadTestFile(test, reloadTestConfig)
+	defer cleanup()
+
+	reloader := newConfigReloader(unitconversion.LoadOptions{}, path)
+	assert.NoError(test, reloader.reload())
+	previous := currentConverter()
+	loadedAt := reloader.Status().LoadedAt
+
+	assert.NoError(test, ioutil.WriteFile(path, []byte(`
+conversions:
+  - from: cm
+    to: m
+    formula: magnitude * 100
+    testFixtures:
+      - input: 100
+        expected: 1
+`), 0644))
+	assert.Error(test, reloader.reload())
+	assert.True(test, previous == currentConverter())
+
+	status := reloader.Status()
+	assert.NotEmpty(test, status.LastError)
+	assert.Equal(test, loadedAt, status.LoadedAt)
+
+	recorder := performRequest(reloader.statusHandler, http.MethodGet, "/status", "")
+	assert.Equal(test, http.StatusOK, recorder.Code)
+	assert.Contains(test, recorder.Body.String(), `"lastError":`)
+}
+
+const reloadTestExtraConversion = "  - from: mm\n    to: cm\n    formula: magnitude / 10\n    testFixtures:\n      - input: 10\n        expected: 1\n"
+
+func TestConfigReloaderWatch(test *testing.T) {
+	path, cleanup := newReloadTestFile(test, reloadTestConfig)
+	defer cleanup()
+
+	reloader := newConfigReloader(unitconversion.LoadOptions{}, path)
+	assert.NoError(test, reloader.reload())
+	assert.False(test, reloader.changed())
+
+	// The new modification time is set explicitly as a file system may not notice a write within the same second
+	assert.NoError(test, ioutil.WriteFile(path, []byte(reloadTestConfig+reloadTestExtraConversion), 0644))
+	modified := time.Now().Add(time.Minute)
+	assert.NoError(test, os.Chtimes(path, modified, modified))
+	assert.True(test, reloader.changed())
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go reloader.watch(echo.New().Logger, make(chan os.Signal), 10*time.Millisecond, stop)
+
+	assert.Eventually(test, func() bool { return reloader.Status().Conversions == 2 }, time.Second, 10*time.Millisecond)
+	assert.Len(test, currentConverter().Conversions, 2)
+	assert.False(test, reloader.changed())
+}
+
+func TestConfigReloaderWatchWithSignal(test *testing.T) {
+	path, cleanup := newReloadTestFile(test, reloadTestConfig)
+	defer cleanup()
+
+	reloader := newConfigReloader(unitconversion.LoadOptions{}, path)
+	assert.NoError(test, reloader.reload())
+
+	signals := make(chan os.Signal)
+	stop := make(chan struct{})
+	defer close(stop)
+	go reloader.watch(echo.New().Logger, signals, time.Hour, stop)
+
+	assert.NoError(test, ioutil.WriteFile(path, []byte(reloadTestConfig+reloadTestExtraConversion), 0644))
+	signals <- os.Interrupt
+
+	assert.Eventually(test, func() bool { return reloader.Status().Conversions == 2 }, time.Second, 10*time.Millisecond)
+	assert.Len(test, currentConverter().Conversions, 2)
+}
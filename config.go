@@ -0,0 +1,709 @@
+package unitconversion
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	validator "gopkg.in/go-playground/validator.v9"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// ConfigError is a problem at a position in a configuration file, File is empty for configurations that were not read from a file and Column is zero when only the line is known
+type ConfigError struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (configError *ConfigError) Error() string {
+	position := configError.File
+	if configError.Line > 0 {
+		if position != "" {
+			position += ":"
+		}
+		position += strconv.Itoa(configError.Line)
+		if configError.Column > 0 {
+			position += ":" + strconv.Itoa(configError.Column)
+		}
+	}
+
+	if position == "" {
+		return configError.Message
+	}
+
+	return position + ": " + configError.Message
+}
+
+// ConfigErrors is every problem found while loading a configuration, in the order they were found
+type ConfigErrors []*ConfigError
+
+func (configErrors ConfigErrors) Error() string {
+	messages := []string{}
+	for _, configError := range configErrors {
+		messages = append(messages, configError.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// configSource is the position of an entry in a configuration file, builtin entries are from the default unit library
+type configSource struct {
+	file    string
+	line    int
+	column  int
+	builtin bool
+}
+
+func sourceOf(file string, node *yaml.Node) configSource {
+	return configSource{file: file, line: node.Line, column: node.Column}
+}
+
+func (source configSource) String() string {
+	if source.file == "" {
+		return fmt.Sprintf("line %d", source.line)
+	}
+
+	return fmt.Sprintf("%s:%d", source.file, source.line)
+}
+
+func (source configSource) errorf(format string, arguments ...interface{}) *ConfigError {
+	return &ConfigError{File: source.file, Line: source.line, Column: source.column, Message: fmt.Sprintf(format, arguments...)}
+}
+
+// configSections are the keys that are allowed at the top of a configuration file, in the order they are applied
+var configSections = []string{"version", "include", "unitStyle", "preferredUnits", "removePreferredUnits", "units", "conversions", "removeConversions"}
+
+// unitOverlay is an entry under units, Override allows it to replace a unit with the same name from an earlier file
+type unitOverlay struct {
+	UnitDefinition `yaml:",inline"`
+	Override       bool `yaml:"override"`
+}
+
+// conversionOverlay is an entry under conversions, Override allows it to replace a conversion with the same units from an earlier file
+type conversionOverlay struct {
+	Conversion `yaml:",inline"`
+	Override   bool `yaml:"override"`
+}
+
+// ConversionReference identifies a conversion by its units, used under removeConversions
+type ConversionReference struct {
+	From string `yaml:"from" validate:"required"`
+	To   string `yaml:"to" validate:"required"`
+}
+
+// yamlLinePattern matches the position in the errors from the yaml package, which only has the line
+var yamlLinePattern = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+func yamlErrors(file string, err error) (configErrors []*ConfigError) {
+	messages := []string{err.Error()}
+	if typeError, ok := err.(*yaml.TypeError); ok {
+		messages = typeError.Errors
+	}
+
+	for _, message := range messages {
+		configError := &ConfigError{File: file, Message: message}
+		if match := yamlLinePattern.FindStringSubmatch(message); match != nil {
+			configError.Line, _ = strconv.Atoi(match[1])
+			configError.Message = match[2]
+		}
+
+		configErrors = append(configErrors, configError)
+	}
+
+	return
+}
+
+// yamlField is a struct field as seen by the yaml package
+type yamlField struct {
+	name      string
+	fieldName string
+	valueType reflect.Type
+}
+
+// yamlFields lists the fields that the yaml package decodes into a struct type, including the fields of inlined structs
+func yamlFields(structType reflect.Type) (fields []yamlField) {
+	for index := 0; index < structType.NumField(); index++ {
+		field := structType.Field(index)
+		tag := strings.Split(field.Tag.Get("yaml"), ",")
+		if tag[0] == "-" || field.PkgPath != "" {
+			continue
+		}
+
+		if len(tag) > 1 && tag[1] == "inline" {
+			fields = append(fields, yamlFields(field.Type)...)
+			continue
+		}
+
+		name := tag[0]
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		fields = append(fields, yamlField{name: name, fieldName: field.Name, valueType: field.Type})
+	}
+
+	return
+}
+
+// maxQuotedLength is the number of characters of a value that yamlKind quotes in an error message
+const maxQuotedLength = 40
+
+func yamlKind(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	}
+
+	// Only the start of a long value is quoted, it may be the content of a file that was given by mistake
+	value := []rune(node.Value)
+	if len(value) > maxQuotedLength {
+		return fmt.Sprintf("%q...", string(value[:maxQuotedLength]))
+	}
+
+	return fmt.Sprintf("%q", node.Value)
+}
+
+// checkNode compares node with the type that it will be decoded into and reports unknown fields and values of the wrong kind, so that every problem gets a line and column
+func checkNode(file string, node *yaml.Node, valueType reflect.Type) (configErrors []*ConfigError) {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	if node.Tag == "!!null" {
+		return
+	}
+
+	source := sourceOf(file, node)
+	switch valueType.Kind() {
+	case reflect.Ptr:
+		return checkNode(file, node, valueType.Elem())
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return append(configErrors, source.errorf("Expected a mapping but got %s", yamlKind(node)))
+		}
+
+		fields := yamlFields(valueType)
+		names := []string{}
+		for _, field := range fields {
+			names = append(names, field.name)
+		}
+
+		for index := 0; index+1 < len(node.Content); index += 2 {
+			key, value := node.Content[index], node.Content[index+1]
+			found := false
+			for _, field := range fields {
+				if field.name == key.Value {
+					found = true
+					configErrors = append(configErrors, checkNode(file, value, field.valueType)...)
+				}
+			}
+
+			if !found {
+				configErrors = append(configErrors, sourceOf(file, key).errorf("Unknown field %q, expected one of %s", key.Value, strings.Join(names, ", ")))
+			}
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return append(configErrors, source.errorf("Expected a list but got %s", yamlKind(node)))
+		}
+
+		for _, item := range node.Content {
+			configErrors = append(configErrors, checkNode(file, item, valueType.Elem())...)
+		}
+	case reflect.String:
+		if node.Kind != yaml.ScalarNode {
+			configErrors = append(configErrors, source.errorf("Expected a string but got %s", yamlKind(node)))
+		}
+	case reflect.Float32, reflect.Float64, reflect.Int, reflect.Int64:
+		if node.Kind != yaml.ScalarNode || (node.Tag != "!!int" && node.Tag != "!!float") {
+			configErrors = append(configErrors, source.errorf("Expected a number but got %s", yamlKind(node)))
+		}
+	case reflect.Bool:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!bool" {
+			configErrors = append(configErrors, source.errorf("Expected true or false but got %s", yamlKind(node)))
+		}
+	}
+
+	return
+}
+
+// validationErrors reports the fields of a decoded value that fail their validate tags, at the position of the field or of the value when the field is missing
+func validationErrors(file string, node *yaml.Node, value interface{}) (configErrors []*ConfigError) {
+	err := validator.New().Struct(value)
+	fieldErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		if err != nil {
+			configErrors = append(configErrors, sourceOf(file, node).errorf("%v", err))
+		}
+		return
+	}
+
+	fields := yamlFields(reflect.Indirect(reflect.ValueOf(value)).Type())
+	for _, fieldError := range fieldErrors {
+		fieldName := strings.SplitN(fieldError.StructField(), "[", 2)[0]
+		name := fieldName
+		for _, field := range fields {
+			if field.fieldName == fieldName {
+				name = field.name
+			}
+		}
+
+		source := sourceOf(file, node)
+		for index := 0; index+1 < len(node.Content); index += 2 {
+			if node.Content[index].Value == name {
+				source = sourceOf(file, node.Content[index+1])
+			}
+		}
+
+		if strings.HasPrefix(fieldError.Tag(), "required") {
+			configErrors = append(configErrors, source.errorf("The field %q is required", name))
+		} else {
+			configErrors = append(configErrors, source.errorf("The field %q does not pass the check %q", name, fieldError.Tag()))
+		}
+	}
+
+	return
+}
+
+// configLoader merges configuration files, each file overlays the files loaded before it, problems are collected in errors so that all of them can be reported at once
+type configLoader struct {
+	converter         Converter
+	unitSources       []configSource
+	conversionSources []configSource
+	loading           map[string]bool
+	loaded            map[string]bool
+	files             []string
+	errors            ConfigErrors
+	builtin           bool
+	options           LoadOptions
+}
+
+// LoadOptions changes how LoadConverterFiles loads the configuration
+type LoadOptions struct {
+	// Defaults loads the configuration on top of the default unit library
+	Defaults bool
+	// Degraded leaves conversions that fail their test fixtures out of the converter instead of failing the whole configuration, they are kept in Converter.ExcludedConversions and reported by Converter.TestReport
+	Degraded bool
+}
+
+func newConfigLoader(options LoadOptions) *configLoader {
+	return &configLoader{loading: map[string]bool{}, loaded: map[string]bool{}, options: options}
+}
+
+func (loader *configLoader) fail(configErrors ...*ConfigError) {
+	loader.errors = append(loader.errors, configErrors...)
+}
+
+// decode checks and decodes node into value, false is returned if there were any problems
+func (loader *configLoader) decode(file string, node *yaml.Node, value interface{}) bool {
+	configErrors := checkNode(file, node, reflect.TypeOf(value))
+	if len(configErrors) == 0 {
+		if err := node.Decode(value); err != nil {
+			configErrors = yamlErrors(file, err)
+		}
+	}
+
+	loader.fail(configErrors...)
+
+	return len(configErrors) == 0
+}
+
+// sequence returns the items of a list, null is an empty list
+func (loader *configLoader) sequence(file string, node *yaml.Node) (items []*yaml.Node) {
+	if node.Tag == "!!null" {
+		return
+	}
+
+	if node.Kind != yaml.SequenceNode {
+		loader.fail(sourceOf(file, node).errorf("Expected a list but got %s", yamlKind(node)))
+		return
+	}
+
+	return node.Content
+}
+
+// strings decodes a list of strings
+func (loader *configLoader) strings(file string, node *yaml.Node) (values []string, sources []configSource) {
+	for _, item := range loader.sequence(file, node) {
+		var value string
+		if loader.decode(file, item, &value) {
+			values = append(values, value)
+			sources = append(sources, sourceOf(file, item))
+		}
+	}
+
+	return
+}
+
+// loadPath loads a file, or all .yml and .yaml files in a directory in lexical order
+func (loader *configLoader) loadPath(path string, source configSource) {
+	info, err := os.Stat(path)
+	if err != nil {
+		loader.fail(source.errorf("%v", err))
+		return
+	}
+
+	if !info.IsDir() {
+		loader.loadFile(path, source)
+		return
+	}
+
+	loader.files = append(loader.files, path)
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		loader.fail(source.errorf("%v", err))
+		return
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		extension := strings.ToLower(filepath.Ext(entry.Name()))
+		if !entry.IsDir() && (extension == ".yml" || extension == ".yaml") {
+			names = append(names, entry.Name())
+		}
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		loader.loadFile(filepath.Join(path, name), source)
+	}
+}
+
+func (loader *configLoader) loadFile(path string, source configSource) {
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		loader.fail(source.errorf("%v", err))
+		return
+	}
+
+	if loader.loading[absolutePath] {
+		loader.fail(source.errorf("%s includes itself", path))
+		return
+	}
+
+	// A file that is included more than once, e.g. by two other files, is only applied the first time
+	if loader.loaded[absolutePath] {
+		return
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		loader.fail(source.errorf("%v", err))
+		return
+	}
+
+	loader.loading[absolutePath] = true
+	loader.files = append(loader.files, path)
+	loader.loadDocument(raw, path)
+	delete(loader.loading, absolutePath)
+	loader.loaded[absolutePath] = true
+}
+
+// loadDocument applies one configuration file, includes are loaded first so that the including file overlays them
+func (loader *configLoader) loadDocument(raw []byte, file string) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(raw, &root); err != nil {
+		loader.fail(yamlErrors(file, err)...)
+		return
+	}
+
+	// An empty file is an empty configuration
+	if len(root.Content) == 0 {
+		return
+	}
+
+	document := root.Content[0]
+	if document.Kind != yaml.MappingNode {
+		loader.fail(sourceOf(file, document).errorf("Expected a mapping with %s but got %s", strings.Join(configSections, ", "), yamlKind(document)))
+		return
+	}
+
+	// Older versions are upgraded in memory before the sections are checked, so that only the current format has to be understood below
+	if configErrors := migrateDocument(file, document); len(configErrors) > 0 {
+		loader.fail(configErrors...)
+		return
+	}
+
+	sections := map[string]*yaml.Node{}
+	keys := map[string]*yaml.Node{}
+	for index := 0; index+1 < len(document.Content); index += 2 {
+		key := document.Content[index]
+		switch {
+		case !containsString(configSections, key.Value):
+			loader.fail(sourceOf(file, key).errorf("Unknown field %q, expected one of %s", key.Value, strings.Join(configSections, ", ")))
+		case keys[key.Value] != nil:
+			loader.fail(sourceOf(file, key).errorf("The field %q is already given at line %d", key.Value, keys[key.Value].Line))
+		default:
+			keys[key.Value] = key
+			sections[key.Value] = document.Content[index+1]
+		}
+	}
+
+	// version has already been handled by migrateDocument
+	apply := map[string]func(file string, node *yaml.Node){
+		"include":              loader.applyIncludes,
+		"unitStyle":            loader.applyUnitStyle,
+		"preferredUnits":       loader.applyPreferredUnits,
+		"removePreferredUnits": loader.applyRemovedPreferredUnits,
+		"units":                loader.applyUnits,
+		"conversions":          loader.applyConversions,
+		"removeConversions":    loader.applyRemovedConversions,
+	}
+	for _, section := range configSections {
+		if node := sections[section]; node != nil && apply[section] != nil {
+			apply[section](file, node)
+		}
+	}
+}
+
+func (loader *configLoader) applyIncludes(file string, node *yaml.Node) {
+	// YAML that is not read from a file may come from anywhere, so it must not be able to read files
+	if file == "" {
+		loader.fail(sourceOf(file, node).errorf("include is only supported in configuration files, load them with LoadConverter"))
+		return
+	}
+
+	directory := filepath.Dir(file)
+
+	includes, sources := loader.strings(file, node)
+	for index, include := range includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(directory, include)
+		}
+
+		loader.loadPath(include, sources[index])
+	}
+}
+
+func (loader *configLoader) applyUnitStyle(file string, node *yaml.Node) {
+	var unitStyle UnitStyle
+	if !loader.decode(file, node, &unitStyle) {
+		return
+	}
+
+	if unitStyle != UnitStyleSymbol && unitStyle != UnitStyleUCUM {
+		loader.fail(sourceOf(file, node).errorf("The unit style must be %q or %q, got %q", UnitStyleSymbol, UnitStyleUCUM, unitStyle))
+		return
+	}
+
+	loader.converter.UnitStyle = unitStyle
+}
+
+func (loader *configLoader) applyPreferredUnits(file string, node *yaml.Node) {
+	units, _ := loader.strings(file, node)
+	for _, unit := range units {
+		if !containsString(loader.converter.PreferredUnits, unit) {
+			loader.converter.PreferredUnits = append(loader.converter.PreferredUnits, unit)
+		}
+	}
+}
+
+func (loader *configLoader) applyRemovedPreferredUnits(file string, node *yaml.Node) {
+	units, sources := loader.strings(file, node)
+	for index, unit := range units {
+		if !containsString(loader.converter.PreferredUnits, unit) {
+			loader.fail(sources[index].errorf("The preferred unit %q can not be removed as it is not in the list", unit))
+			continue
+		}
+
+		preferredUnits := []string{}
+		for _, preferredUnit := range loader.converter.PreferredUnits {
+			if preferredUnit != unit {
+				preferredUnits = append(preferredUnits, preferredUnit)
+			}
+		}
+		loader.converter.PreferredUnits = preferredUnits
+	}
+}
+
+func (loader *configLoader) applyUnits(file string, node *yaml.Node) {
+	for _, item := range loader.sequence(file, node) {
+		source := sourceOf(file, item)
+		source.builtin = loader.builtin
+		var overlay unitOverlay
+		if !loader.decode(file, item, &overlay) {
+			continue
+		}
+
+		if configErrors := validationErrors(file, item, &overlay.UnitDefinition); len(configErrors) > 0 {
+			loader.fail(configErrors...)
+			continue
+		}
+
+		existing := -1
+		for unitIndex, definition := range loader.converter.UnitDefinitions {
+			if definition.Name == overlay.Name {
+				existing = unitIndex
+			}
+		}
+
+		switch {
+		case existing < 0:
+			loader.converter.UnitDefinitions = append(loader.converter.UnitDefinitions, overlay.UnitDefinition)
+			loader.unitSources = append(loader.unitSources, source)
+		case overlay.Override || loader.unitSources[existing].builtin:
+			loader.converter.UnitDefinitions[existing] = overlay.UnitDefinition
+			loader.unitSources[existing] = source
+		default:
+			loader.fail(source.errorf("The unit %q is already defined at %s, set override: true to replace it", overlay.Name, loader.unitSources[existing]))
+		}
+	}
+}
+
+func (loader *configLoader) applyConversions(file string, node *yaml.Node) {
+	for _, item := range loader.sequence(file, node) {
+		source := sourceOf(file, item)
+		source.builtin = loader.builtin
+		var overlay conversionOverlay
+		if !loader.decode(file, item, &overlay) {
+			continue
+		}
+
+		if configErrors := validationErrors(file, item, &overlay.Conversion); len(configErrors) > 0 {
+			loader.fail(configErrors...)
+			continue
+		}
+
+		if err := overlay.Conversion.Test(); err != nil {
+			if loader.options.Degraded {
+				loader.exclude(overlay.Conversion)
+			} else {
+				loader.fail(source.errorf("%v", err))
+			}
+			continue
+		}
+
+		loader.removeExcluded(overlay.From, overlay.To)
+
+		// Conversions with the same units in a single file are left to Converter.Lint, conflicts are only in between files
+		existing := -1
+		for conversionIndex := range loader.converter.Conversions {
+			conversion := &loader.converter.Conversions[conversionIndex]
+			if conversion.From == overlay.From && conversion.To == overlay.To && loader.conversionSources[conversionIndex].file != file {
+				existing = conversionIndex
+				break
+			}
+		}
+
+		switch {
+		case existing < 0:
+			loader.converter.Conversions = append(loader.converter.Conversions, overlay.Conversion)
+			loader.conversionSources = append(loader.conversionSources, source)
+		case overlay.Override || loader.conversionSources[existing].builtin:
+			loader.converter.Conversions[existing] = overlay.Conversion
+			loader.conversionSources[existing] = source
+		default:
+			loader.fail(source.errorf("The conversion from %q to %q is already defined at %s, set override: true to replace it", overlay.From, overlay.To, loader.conversionSources[existing]))
+		}
+	}
+}
+
+func (loader *configLoader) applyRemovedConversions(file string, node *yaml.Node) {
+	for _, item := range loader.sequence(file, node) {
+		source := sourceOf(file, item)
+		var reference ConversionReference
+		if !loader.decode(file, item, &reference) {
+			continue
+		}
+
+		if configErrors := validationErrors(file, item, &reference); len(configErrors) > 0 {
+			loader.fail(configErrors...)
+			continue
+		}
+
+		conversions := []Conversion{}
+		sources := []configSource{}
+		for conversionIndex, conversion := range loader.converter.Conversions {
+			if conversion.From != reference.From || conversion.To != reference.To {
+				conversions = append(conversions, conversion)
+				sources = append(sources, loader.conversionSources[conversionIndex])
+			}
+		}
+
+		removedExcluded := loader.removeExcluded(reference.From, reference.To)
+		if len(conversions) == len(loader.converter.Conversions) && !removedExcluded {
+			loader.fail(source.errorf("The conversion from %q to %q can not be removed as it is not defined", reference.From, reference.To))
+			continue
+		}
+
+		loader.converter.Conversions = conversions
+		loader.conversionSources = sources
+	}
+}
+
+// exclude leaves a conversion that failed its test out of the configuration in degraded mode, it replaces an excluded conversion with the same units
+func (loader *configLoader) exclude(conversion Conversion) {
+	loader.removeExcluded(conversion.From, conversion.To)
+	loader.converter.ExcludedConversions = append(loader.converter.ExcludedConversions, conversion)
+}
+
+// removeExcluded forgets the excluded conversions in between from and to, as they have been replaced or removed by a later file
+func (loader *configLoader) removeExcluded(from string, to string) (removed bool) {
+	excluded := []Conversion{}
+	for _, conversion := range loader.converter.ExcludedConversions {
+		if conversion.From == from && conversion.To == to {
+			removed = true
+		} else {
+			excluded = append(excluded, conversion)
+		}
+	}
+
+	if removed {
+		loader.converter.ExcludedConversions = excluded
+	}
+
+	return
+}
+
+// result tests the merged configuration, the conversions have already been tested when they were loaded
+func (loader *configLoader) result() (converter Converter, err error) {
+	if len(loader.errors) == 0 {
+		index, unitsErr := loader.converter.testUnits()
+		if unitsErr != nil {
+			loader.fail(loader.unitSources[index].errorf("%v", unitsErr))
+		}
+	}
+
+	if len(loader.errors) > 0 {
+		err = loader.errors
+		return
+	}
+
+	converter = loader.converter
+
+	return
+}
+
+// LoadConverterFiles loads and tests the configuration in paths according to options and returns every file and directory that was read, e.g. to reload the configuration when any of them changes
+func LoadConverterFiles(options LoadOptions, paths ...string) (converter Converter, files []string, err error) {
+	loader := newConfigLoader(options)
+	if options.Defaults {
+		loader.loadDefaults()
+	}
+
+	for _, path := range paths {
+		loader.loadPath(path, configSource{})
+	}
+
+	converter, err = loader.result()
+	files = loader.files
+
+	return
+}
+
+// LoadConverter loads configuration files and directories of .yml and .yaml files, later files overlay earlier ones and each file can include other files with paths relative to itself, a ConfigErrors with the position of every problem is returned if the configuration is not valid
+func LoadConverter(paths ...string) (converter Converter, err error) {
+	converter, _, err = LoadConverterFiles(LoadOptions{}, paths...)
+
+	return
+}
@@ -0,0 +1,264 @@
+package unitconversion
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFiles(test *testing.T, files map[string]string) (directory string) {
+	directory, err := ioutil.TempDir("", "unit-conversion")
+	assert.NoError(test, err)
+
+	for name, content := range files {
+		path := filepath.Join(directory, name)
+		assert.NoError(test, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(test, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	return
+}
+
+const configTestBase = `preferredUnits:
+  - m
+  - g
+conversions:
+  - from: cm
+    to: m
+    formula: magnitude / 100
+    testFixtures:
+      - input: 100
+        expected: 1
+  - from: m
+    to: cm
+    formula: magnitude * 100
+    testFixtures:
+      - input: 1
+        expected: 100
+`
+
+func TestLoadConverterWithIncludeAndOverlay(test *testing.T) {
+	directory := writeConfigFiles(test, map[string]string{
+		"base.yml": configTestBase,
+		"lab/concentrations.yml": `preferredUnits:
+  - µg/l
+conversions:
+  - from: ng/ml
+    to: µg/l
+    formula: magnitude
+    testFixtures:
+      - input: 1
+        expected: 1
+`,
+		"overlay.yml": `include:
+  - lab/concentrations.yml
+removePreferredUnits:
+  - g
+conversions:
+  - from: cm
+    to: m
+    formula: magnitude * 0.01
+    override: true
+    testFixtures:
+      - input: 100
+        expected: 1
+removeConversions:
+  - from: m
+    to: cm
+`,
+	})
+	defer os.RemoveAll(directory)
+
+	converter, files, err := LoadConverterFiles(LoadOptions{}, filepath.Join(directory, "base.yml"), filepath.Join(directory, "overlay.yml"))
+	assert.NoError(test, err)
+	assert.Equal(test, []string{"m", "µg/l"}, converter.PreferredUnits)
+	assert.Len(test, converter.Conversions, 2)
+	assert.Equal(test, "magnitude * 0.01", converter.Conversions[0].Formula)
+	assert.Equal(test, "ng/ml", converter.Conversions[1].From)
+	assert.Len(test, files, 3)
+}
+
+func TestLoadConverterFromDirectory(test *testing.T) {
+	directory := writeConfigFiles(test, map[string]string{
+		"10-base.yml": configTestBase,
+		"20-inches.yaml": `conversions:
+  - from: in
+    to: m
+    formula: magnitude * 0.0254
+    testFixtures:
+      - input: 1
+        expected: 0.0254
+`,
+		"README.md": "not a configuration",
+	})
+	defer os.RemoveAll(directory)
+
+	converter, err := LoadConverter(directory)
+	assert.NoError(test, err)
+	assert.Len(test, converter.Conversions, 3)
+
+	output, err := converter.Convert(Quantity{Magnitude: 100, Unit: "in"}, "cm")
+	assert.NoError(test, err)
+	assert.InDelta(test, 254, output.Magnitude, 1e-9)
+}
+
+func TestFailLoadConverterWithConflict(test *testing.T) {
+	directory := writeConfigFiles(test, map[string]string{
+		"base.yml": configTestBase,
+		"overlay.yml": `conversions:
+  - from: m
+    to: km
+    formula: magnitude / 1000
+    testFixtures:
+      - input: 1000
+        expected: 1
+  - from: cm
+    to: m
+    formula: magnitude * 0.01
+    testFixtures:
+      - input: 100
+        expected: 1
+`,
+	})
+	defer os.RemoveAll(directory)
+
+	base := filepath.Join(directory, "base.yml")
+	overlay := filepath.Join(directory, "overlay.yml")
+	_, err := LoadConverter(base, overlay)
+	assert.EqualError(test, err, overlay+`:8:5: The conversion from "cm" to "m" is already defined at `+base+`:5, set override: true to replace it`)
+
+	configErrors, ok := err.(ConfigErrors)
+	assert.True(test, ok)
+	assert.Len(test, configErrors, 1)
+	assert.Equal(test, overlay, configErrors[0].File)
+	assert.Equal(test, 8, configErrors[0].Line)
+	assert.Equal(test, 5, configErrors[0].Column)
+}
+
+func TestFailLoadConverterWithIncludeCycle(test *testing.T) {
+	directory := writeConfigFiles(test, map[string]string{
+		"a.yml": "include:\n  - b.yml\n",
+		"b.yml": "include:\n  - a.yml\n",
+	})
+	defer os.RemoveAll(directory)
+
+	_, err := LoadConverter(filepath.Join(directory, "a.yml"))
+	assert.EqualError(test, err, filepath.Join(directory, "b.yml")+":2:5: "+filepath.Join(directory, "a.yml")+" includes itself")
+}
+
+func TestFailLoadConverterWithMissingRemoval(test *testing.T) {
+	directory := writeConfigFiles(test, map[string]string{
+		"base.yml": configTestBase + "removeConversions:\n  - from: m\n    to: km\n",
+	})
+	defer os.RemoveAll(directory)
+
+	_, err := LoadConverter(filepath.Join(directory, "base.yml"))
+	assert.Error(test, err)
+	assert.Contains(test, err.Error(), "base.yml:18:5: The conversion from \"m\" to \"km\" can not be removed")
+
+	_, err = LoadConverter(filepath.Join(directory, "missing.yml"))
+	assert.Error(test, err)
+}
+
+func TestFailNewConverterFromYAMLWithEveryProblem(test *testing.T) {
+	_, err := NewConverterFromYAML([]byte(`preferedUnits:
+  - m
+unitStyle: metric
+units:
+  - dimension: length
+conversions:
+  - from: cm
+    to: m
+    formula: magnitude / 100
+    testFixtures:
+      - input: one hundred
+        expected: 1
+  - from: m
+    to: cm
+    fromula: magnitude * 100
+    testFixtures:
+      - input: 1
+        expected: 100
+  - from: m
+    to: mm
+    formula: magnitude * 1000
+    testFixtures:
+      - input: 1
+        expected: 100
+`))
+
+	configErrors, ok := err.(ConfigErrors)
+	assert.True(test, ok)
+
+	messages := []string{}
+	for _, configError := range configErrors {
+		messages = append(messages, configError.Error())
+	}
+
+	assert.Equal(test, []string{
+		`1:1: Unknown field "preferedUnits", expected one of version, include, unitStyle, preferredUnits, removePreferredUnits, units, conversions, removeConversions`,
+		`3:12: The unit style must be "symbol" or "ucum", got "metric"`,
+		`5:5: The field "name" is required`,
+		`11:16: Expected a number but got "one hundred"`,
+		`15:5: Unknown field "fromula", expected one of from, to, formula, table, testFixtures, override`,
+		`19:5: Conversion test failed, from "m" to "mm" with formula "magnitude * 1000" and input 1.000000 expected 100.000000 but got 1000.000000`,
+	}, messages)
+}
+
+func TestFailNewConverterFromYAMLWithDuplicateSection(test *testing.T) {
+	_, err := NewConverterFromYAML([]byte("preferredUnits:\n  - m\npreferredUnits:\n  - g\n"))
+	assert.EqualError(test, err, `3:1: The field "preferredUnits" is already given at line 1`)
+}
+
+func TestFailNewConverterFromYAMLWithInclude(test *testing.T) {
+	_, err := NewConverterFromYAML([]byte("include:\n  - /etc/passwd\n"))
+	assert.EqualError(test, err, "2:3: include is only supported in configuration files, load them with LoadConverter")
+}
+
+func TestFailNewConverterFromYAMLWithLongValue(test *testing.T) {
+	_, err := NewConverterFromYAML([]byte(strings.Repeat("secret ", 20)))
+	assert.Error(test, err)
+	assert.Contains(test, err.Error(), `but got "secret secret secret secret secret secre"...`)
+	assert.NotContains(test, err.Error(), strings.Repeat("secret ", 7))
+}
+
+func TestConfigSchemaMatchesConfigTypes(test *testing.T) {
+	raw, err := ioutil.ReadFile("converter.schema.json")
+	assert.NoError(test, err)
+
+	type schemaObject struct {
+		Properties map[string]interface{} `json:"properties"`
+	}
+	var schema struct {
+		schemaObject
+		Definitions map[string]schemaObject `json:"definitions"`
+	}
+	assert.NoError(test, json.Unmarshal(raw, &schema))
+
+	propertyNames := func(object schemaObject) (names []string) {
+		for name := range object.Properties {
+			names = append(names, name)
+		}
+		return
+	}
+	fieldNames := func(value interface{}) (names []string) {
+		for _, field := range yamlFields(reflect.TypeOf(value)) {
+			names = append(names, field.name)
+		}
+		return
+	}
+
+	assert.ElementsMatch(test, configSections, propertyNames(schema.schemaObject))
+	assert.ElementsMatch(test, fieldNames(unitOverlay{}), propertyNames(schema.Definitions["unit"]))
+	assert.ElementsMatch(test, fieldNames(conversionOverlay{}), propertyNames(schema.Definitions["conversion"]))
+	assert.ElementsMatch(test, fieldNames(ConversionTestFixture{}), propertyNames(schema.Definitions["testFixture"]))
+	assert.ElementsMatch(test, fieldNames(ConversionTable{}), propertyNames(schema.Definitions["table"]))
+	assert.ElementsMatch(test, fieldNames(TableValue{}), propertyNames(schema.Definitions["tableValue"]))
+	assert.ElementsMatch(test, fieldNames(ConversionReference{}), propertyNames(schema.Definitions["conversionReference"]))
+}
@@ -1,11 +1,13 @@
-package main
+// Package unitconversion converts quantities in between units with formulas from a YAML configuration, conversions in between units that are not directly connected are chained automatically. Converter converts single quantities and JSONConverter converts every quantity in a JSON document.
+package unitconversion // import "github.com/tirithen/unit-conversion"
 
 import (
 	"fmt"
+	"math"
+	"sync"
 
 	govaluate "gopkg.in/Knetic/govaluate.v2"
 	validator "gopkg.in/go-playground/validator.v9"
-	yaml "gopkg.in/yaml.v2"
 )
 
 // Quantity defines properties that is needed to make a conversion
@@ -20,12 +22,27 @@ type ConversionTestFixture struct {
 	Expected float64 `yaml:"expected"`
 }
 
-// Conversion defines properties that describes how a value with one unit can be converted into a value in another unit with a formula
+// MagnitudeConverter converts a magnitude from one unit to another, implement it for conversions that can not be written as a formula such as table lookups, iterative solutions or calls into other libraries
+type MagnitudeConverter interface {
+	ConvertMagnitude(magnitude float64) (float64, error)
+}
+
+// ConversionFunc is a function that is used as a MagnitudeConverter
+type ConversionFunc func(magnitude float64) (float64, error)
+
+// ConvertMagnitude calls the function itself
+func (conversionFunc ConversionFunc) ConvertMagnitude(magnitude float64) (float64, error) {
+	return conversionFunc(magnitude)
+}
+
+// Conversion defines properties that describes how a value with one unit can be converted into a value in another unit with a formula, a Table or with Implementation which is used instead of the formula when it is set. Formula is then optional and only describes the conversion in explanations, reports and graphs, see Description
 type Conversion struct {
 	From              string                         `yaml:"from" validate:"required"`
 	To                string                         `yaml:"to" validate:"required"`
-	Formula           string                         `yaml:"formula" validate:"required"`
+	Formula           string                         `yaml:"formula" validate:"required_without_all=Implementation Table"`
 	FormulaExpression *govaluate.EvaluableExpression `yaml:"-"`
+	Table             *ConversionTable               `yaml:"table"`
+	Implementation    MagnitudeConverter             `yaml:"-"`
 	TestFixtures      []ConversionTestFixture        `yaml:"testFixtures" validate:"required,dive,required"`
 }
 
@@ -47,6 +64,17 @@ func (conversion *Conversion) Test() (err error) {
 		return
 	}
 
+	if conversion.Table != nil {
+		if conversion.Formula != "" && conversion.Implementation == nil {
+			err = fmt.Errorf("The conversion from %q to %q has both a formula and a table, only one of them can be used", conversion.From, conversion.To)
+			return
+		}
+
+		if err = conversion.Table.Check(); err != nil {
+			return
+		}
+	}
+
 	for index := range conversion.TestFixtures {
 		fixture := conversion.TestFixtures[index]
 		input := Quantity{Magnitude: fixture.Input, Unit: conversion.From}
@@ -58,7 +86,7 @@ func (conversion *Conversion) Test() (err error) {
 		}
 
 		if output.Magnitude != fixture.Expected {
-			err = fmt.Errorf("Conversion test failed, from %q to %q with formula %q and input %f expected %f but got %f", conversion.From, conversion.To, conversion.Formula, input.Magnitude, expected.Magnitude, output.Magnitude)
+			err = &FixtureError{From: conversion.From, To: conversion.To, Formula: conversion.Description(), Input: input.Magnitude, Expected: expected.Magnitude, Actual: output.Magnitude}
 			return
 		}
 	}
@@ -66,16 +94,69 @@ func (conversion *Conversion) Test() (err error) {
 	return
 }
 
+// Description is Conversion.Formula, or a label for the Implementation or Table when there is no formula, e.g. "table (linear, 7 values)" or "go func". Implementations that are a fmt.Stringer are described by their String method
+func (conversion *Conversion) Description() string {
+	if conversion.Formula != "" {
+		return conversion.Formula
+	}
+
+	if conversion.Implementation != nil {
+		if stringer, ok := conversion.Implementation.(fmt.Stringer); ok {
+			return stringer.String()
+		}
+
+		return "go func"
+	}
+
+	if conversion.Table != nil {
+		return conversion.Table.String()
+	}
+
+	return ""
+}
+
+// finiteMagnitude fails for NaN and infinite results, they can not be written as JSON numbers
+func finiteMagnitude(magnitude float64) (err error) {
+	if math.IsNaN(magnitude) || math.IsInf(magnitude, 0) {
+		err = fmt.Errorf("The result %v is not a finite number", magnitude)
+	}
+
+	return
+}
+
 // Convert takes a Quantity and a Conversion and returns a new Quantity with the result
 func (conversion *Conversion) Convert(input Quantity) (output Quantity, err error) {
 	if input.Unit != conversion.From {
-		err = fmt.Errorf("Conversion from unit mismatch got %q but expected %q", input.Unit, conversion.From)
+		err = &UnitMismatchError{Expected: conversion.From, Actual: input.Unit}
+		return
+	}
+
+	formulaError := &FormulaError{From: conversion.From, To: conversion.To, Formula: conversion.Formula, Input: input.Magnitude}
+	implementation := conversion.Implementation
+	if implementation == nil && conversion.Table != nil {
+		implementation = conversion.Table
+	}
+
+	if implementation != nil {
+		output.Magnitude, formulaError.Err = implementation.ConvertMagnitude(input.Magnitude)
+		if formulaError.Err == nil {
+			formulaError.Err = finiteMagnitude(output.Magnitude)
+		}
+
+		if formulaError.Err != nil {
+			output.Magnitude = 0
+			err = formulaError
+			return
+		}
+
+		output.Unit = conversion.To
 		return
 	}
 
 	if conversion.FormulaExpression == nil {
-		err = conversion.createExpressionFromFormula()
-		if err != nil {
+		formulaError.Err = conversion.createExpressionFromFormula()
+		if formulaError.Err != nil {
+			err = formulaError
 			return
 		}
 	}
@@ -83,12 +164,23 @@ func (conversion *Conversion) Convert(input Quantity) (output Quantity, err erro
 	parameters := make(map[string]interface{}, 8)
 	parameters["magnitude"] = input.Magnitude
 
-	magnitude, err := conversion.FormulaExpression.Evaluate(parameters)
-	if err != nil {
+	result, evaluateErr := conversion.FormulaExpression.Evaluate(parameters)
+	magnitude, isNumber := result.(float64)
+	if evaluateErr == nil && isNumber {
+		evaluateErr = finiteMagnitude(magnitude)
+	}
+
+	if evaluateErr != nil || !isNumber {
+		formulaError.Err = evaluateErr
+		if evaluateErr == nil {
+			formulaError.Err = fmt.Errorf("The result %v is not a number", result)
+		}
+
+		err = formulaError
 		return
 	}
 
-	output.Magnitude = magnitude.(float64)
+	output.Magnitude = magnitude
 	output.Unit = conversion.To
 
 	return
@@ -96,13 +188,30 @@ func (conversion *Conversion) Convert(input Quantity) (output Quantity, err erro
 
 // Converter allows for a Quantity to be converted in between different units
 type Converter struct {
-	PreferredUnits []string     `yaml:"preferredUnits"`
-	Conversions    []Conversion `yaml:"conversions"`
-	PathCache      map[string][]*Conversion
+	PreferredUnits  []string         `yaml:"preferredUnits"`
+	UnitStyle       UnitStyle        `yaml:"unitStyle" validate:"omitempty,oneof=symbol ucum"`
+	UnitDefinitions []UnitDefinition `yaml:"units" validate:"dive"`
+	Conversions     []Conversion     `yaml:"conversions"`
+	// ExcludedConversions failed their test fixtures and were left out when the configuration was loaded with LoadOptions.Degraded, they are never used to convert
+	ExcludedConversions []Conversion `yaml:"-"`
+	PathCache           map[string][]*Conversion
+	// pathCacheLock guards PathCache, copies of a Converter share both the map and the lock
+	pathCacheLock *sync.RWMutex
 }
 
 // Test tests that the converter and all it's conversions are in a good state
 func (converter *Converter) Test() (err error) {
+	validate := validator.New()
+	err = validate.Struct(converter)
+	if err != nil {
+		return
+	}
+
+	_, err = converter.testUnits()
+	if err != nil {
+		return
+	}
+
 	for index := range converter.Conversions {
 		err = converter.Conversions[index].Test()
 		if err != nil {
@@ -133,13 +242,54 @@ func (converter *Converter) filterConversionsByFrom(from string, blacklist []*Co
 	return
 }
 
-func (converter *Converter) getPath(from string, to string, previousPath []*Conversion) (path []*Conversion, err error) {
+// fallbackPathCacheLock guards Converter.PathCache for converters that were not created with newPathCache, e.g. a Converter literal
+var fallbackPathCacheLock sync.RWMutex
+
+// newPathCache gives the converter an empty PathCache with a lock of its own, copies made after this share both
+func (converter *Converter) newPathCache() {
+	converter.PathCache = make(map[string][]*Conversion)
+	converter.pathCacheLock = &sync.RWMutex{}
+}
+
+// cacheLock returns the lock that guards Converter.PathCache
+func (converter *Converter) cacheLock() *sync.RWMutex {
+	if converter.pathCacheLock != nil {
+		return converter.pathCacheLock
+	}
+
+	return &fallbackPathCacheLock
+}
+
+// getPath finds a conversion path from the unit from to the unit to, cached reports if the path was taken from Converter.PathCache
+func (converter *Converter) getPath(from string, to string) (path []*Conversion, cached bool, err error) {
 	cacheKey := from + " => " + to
-	if cachedPath, ok := converter.PathCache[cacheKey]; ok {
-		path = cachedPath
+	lock := converter.cacheLock()
+	lock.RLock()
+	path, cached = converter.PathCache[cacheKey]
+	lock.RUnlock()
+	if cached {
+		return
+	}
+
+	path, err = converter.searchPath(from, to, []*Conversion{}, map[string]bool{})
+	if err != nil {
+		err = &NoPathError{From: from, To: to}
 		return
 	}
 
+	lock.Lock()
+	if converter.PathCache == nil {
+		converter.PathCache = make(map[string][]*Conversion)
+	}
+	converter.PathCache[cacheKey] = path
+	lock.Unlock()
+
+	return
+}
+
+// searchPath does a depth first search for a path from the unit from to the unit to that continues previousPath, only whole paths are cached by getPath as partial paths include the conversions in previousPath. visited holds the units that have already been searched, so that each unit is only searched once and a failed search does not walk every route through the graph
+func (converter *Converter) searchPath(from string, to string, previousPath []*Conversion, visited map[string]bool) (path []*Conversion, err error) {
+	visited[from] = true
 	edge := converter.filterConversionsByFrom(from, previousPath)
 
 	for index := range edge {
@@ -152,12 +302,12 @@ func (converter *Converter) getPath(from string, to string, previousPath []*Conv
 
 	for index := range edge {
 		node := edge[index]
-		path, err = converter.getPath(node.To, to, append(previousPath, node))
+		if visited[node.To] {
+			continue
+		}
+
+		path, err = converter.searchPath(node.To, to, append(previousPath, node), visited)
 		if err == nil && len(path) > 0 {
-			if converter.PathCache == nil {
-				converter.PathCache = make(map[string][]*Conversion)
-			}
-			converter.PathCache[cacheKey] = path
 			return
 		}
 	}
@@ -168,14 +318,80 @@ func (converter *Converter) getPath(from string, to string, previousPath []*Conv
 	return
 }
 
-// Convert finds a conversion path and converts a Quantity if possible
-func (converter *Converter) Convert(input Quantity, to string) (output Quantity, err error) {
-	path, err := converter.getPath(input.Unit, to, []*Conversion{})
-	if err != nil {
+func (converter *Converter) hasUnit(unit string) bool {
+	for index := range converter.Conversions {
+		if converter.Conversions[index].From == unit || converter.Conversions[index].To == unit {
+			return true
+		}
+	}
+
+	_, defined := converter.unitDefinition(unit)
+
+	return defined
+}
+
+// ResolveUnit maps a unit written as an alias, a UCUM code or a display symbol onto the unit name used by the conversions, unknown units are returned unchanged
+func (converter *Converter) ResolveUnit(unit string) string {
+	if converter.hasUnit(unit) {
+		return unit
+	}
+
+	if name, ok := converter.unitFromAlias(unit); ok {
+		return name
+	}
+
+	code, symbol := unitForms(unit)
+	if converter.hasUnit(symbol) {
+		return symbol
+	}
+
+	if converter.hasUnit(code) {
+		return code
+	}
+
+	return unit
+}
+
+// formatUnit writes a unit according to Converter.UnitStyle
+func (converter *Converter) formatUnit(unit string) string {
+	if converter.UnitStyle == UnitStyleUCUM {
+		code, _ := unitForms(unit)
+		return code
+	}
+
+	return unit
+}
+
+// FindPath returns the conversions that a quantity goes through from the unit from to the unit to, units can be given as aliases or UCUM codes and the same unit gives an empty path
+func (converter *Converter) FindPath(from string, to string) (path []*Conversion, err error) {
+	path, _, err = converter.findPath(from, to)
+
+	return
+}
+
+// findPath resolves the units from and to and finds the conversion path in between them, units that are the same gives an empty path
+func (converter *Converter) findPath(from string, to string) (path []*Conversion, cached bool, err error) {
+	from = converter.ResolveUnit(from)
+	to = converter.ResolveUnit(to)
+	for _, unit := range []string{from, to} {
+		if !converter.hasUnit(unit) {
+			err = &UnknownUnitError{Unit: unit}
+			return
+		}
+	}
+
+	if from == to {
+		path = []*Conversion{}
 		return
 	}
 
+	return converter.getPath(from, to)
+}
+
+// applyPath converts a Quantity with each Conversion in path and writes the unit according to Converter.UnitStyle
+func (converter *Converter) applyPath(input Quantity, path []*Conversion) (output Quantity, err error) {
 	output = input
+	output.Unit = converter.ResolveUnit(input.Unit)
 	for index := range path {
 		output, err = path[index].Convert(output)
 		if err != nil {
@@ -184,21 +400,51 @@ func (converter *Converter) Convert(input Quantity, to string) (output Quantity,
 		}
 	}
 
+	output.Unit = converter.formatUnit(output.Unit)
+
 	return
 }
 
-// ConvertToPreferredUnit works as Convert but selects the to unit from the Converter.PreferredUnits list
-func (converter *Converter) ConvertToPreferredUnit(input Quantity) (output Quantity, err error) {
-	to := ""
+// convert converts a Quantity along the conversion path to the unit to and returns the path that was used, a quantity that already has the unit to is returned with an empty path
+func (converter *Converter) convert(input Quantity, to string) (output Quantity, path []*Conversion, err error) {
+	path, _, err = converter.findPath(input.Unit, to)
+	if err != nil {
+		return
+	}
+
+	output, err = converter.applyPath(input, path)
+
+	return
+}
+
+// preferredUnit selects the unit in Converter.PreferredUnits that a quantity with the unit from can be converted to
+func (converter *Converter) preferredUnit(from string) (to string, err error) {
+	resolvedFrom := converter.ResolveUnit(from)
 	for _, preferredUnit := range converter.PreferredUnits {
-		_, pathError := converter.getPath(input.Unit, preferredUnit, []*Conversion{})
+		_, _, pathError := converter.getPath(resolvedFrom, preferredUnit)
 		if pathError == nil {
 			to = preferredUnit
 		}
 	}
 
 	if to == "" {
-		err = fmt.Errorf("Unable to find a preferred unit for %q, conversion not possible", input.Unit)
+		err = &NoPreferredUnitError{Unit: from}
+	}
+
+	return
+}
+
+// Convert finds a conversion path and converts a Quantity if possible, units can be given as configured or as UCUM codes, use ConvertExplained to also get each step of the conversion
+func (converter *Converter) Convert(input Quantity, to string) (output Quantity, err error) {
+	output, _, err = converter.convert(input, to)
+
+	return
+}
+
+// ConvertToPreferredUnit works as Convert but selects the to unit from the Converter.PreferredUnits list
+func (converter *Converter) ConvertToPreferredUnit(input Quantity) (output Quantity, err error) {
+	to, err := converter.preferredUnit(input.Unit)
+	if err != nil {
 		return
 	}
 
@@ -207,19 +453,141 @@ func (converter *Converter) ConvertToPreferredUnit(input Quantity) (output Quant
 	return
 }
 
-// NewConverterFromYAML is used to parse and verify YAML data into a Converter
-func NewConverterFromYAML(raw []byte) (converter Converter, err error) {
-	err = yaml.Unmarshal(raw, &converter)
+// ConversionResult is a converted Quantity together with the units it was converted through and any warnings about the result
+type ConversionResult struct {
+	Quantity
+	Path        []string     `json:"path"`
+	Warnings    []string     `json:"warnings"`
+	Explanation *Explanation `json:"explanation,omitempty"`
+}
+
+// ConversionHop is one Conversion in a conversion path, Magnitude is the magnitude after the Conversion was applied
+type ConversionHop struct {
+	From      string  `json:"from"`
+	To        string  `json:"to"`
+	Formula   string  `json:"formula"`
+	Magnitude float64 `json:"magnitude"`
+}
+
+// Explanation describes how a ConversionResult was calculated, Cached reports if the conversion path was taken from Converter.PathCache
+type Explanation struct {
+	Hops   []ConversionHop `json:"hops"`
+	Cached bool            `json:"cached"`
+}
+
+// conversionPlan is everything about a conversion in between two units that does not depend on the magnitude, so that it can be shared by many quantities
+type conversionPlan struct {
+	path     []*Conversion
+	units    []string
+	warnings []string
+	cached   bool
+	err      error
+}
+
+// maxExactMagnitude is the largest magnitude where every integer can be represented by a float64
+const maxExactMagnitude = 1 << 53
+
+// planConversion finds the path from the unit from to the unit to, or to the preferred unit when to is empty
+func (converter *Converter) planConversion(from string, to string) (plan conversionPlan) {
+	plan.warnings = []string{}
+
+	if to == "" {
+		to, plan.err = converter.preferredUnit(from)
+		if plan.err != nil {
+			return
+		}
+
+		plan.warnings = append(plan.warnings, fmt.Sprintf("No unit to convert to was given, the preferred unit %q was used", to))
+	}
+
+	for _, unit := range []string{from, to} {
+		if resolved := converter.ResolveUnit(unit); resolved != unit {
+			plan.warnings = append(plan.warnings, fmt.Sprintf("The unit %q was interpreted as %q", unit, resolved))
+		}
+	}
+
+	plan.path, plan.cached, plan.err = converter.findPath(from, to)
+	if plan.err != nil {
+		return
+	}
+
+	plan.units = []string{converter.ResolveUnit(from)}
+	for _, conversion := range plan.path {
+		plan.units = append(plan.units, conversion.To)
+	}
+
+	return
+}
+
+// applyPlan converts input with a plan from planConversion
+func (converter *Converter) applyPlan(input Quantity, plan conversionPlan) (result ConversionResult, err error) {
+	if plan.err != nil {
+		err = plan.err
+		return
+	}
+
+	result.Quantity, err = converter.applyPath(input, plan.path)
 	if err != nil {
-		converter = Converter{}
+		result = ConversionResult{}
 		return
 	}
 
-	err = converter.Test()
+	result.Path = plan.units
+	result.Warnings = append([]string{}, plan.warnings...)
+	if math.Abs(input.Magnitude) > maxExactMagnitude {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("The magnitude %g is too large to be converted without losing precision", input.Magnitude))
+	}
+
+	return
+}
+
+// explainPlan converts input with a plan from planConversion one Conversion at a time and records each step
+func (converter *Converter) explainPlan(input Quantity, plan conversionPlan) (explanation *Explanation, err error) {
+	explanation = &Explanation{Hops: []ConversionHop{}, Cached: plan.cached}
+	quantity := Quantity{Magnitude: input.Magnitude, Unit: converter.ResolveUnit(input.Unit)}
+	for _, conversion := range plan.path {
+		quantity, err = conversion.Convert(quantity)
+		if err != nil {
+			explanation = nil
+			return
+		}
+
+		explanation.Hops = append(explanation.Hops, ConversionHop{
+			From:      conversion.From,
+			To:        conversion.To,
+			Formula:   conversion.Description(),
+			Magnitude: quantity.Magnitude,
+		})
+	}
+
+	return
+}
+
+// ConvertExplained works as ConvertQuantity and also sets ConversionResult.Explanation with each Conversion that was used, which is useful to find out why a result looks wrong
+func (converter *Converter) ConvertExplained(input Quantity, to string) (result ConversionResult, err error) {
+	plan := converter.planConversion(input.Unit, to)
+	result, err = converter.applyPlan(input, plan)
 	if err != nil {
-		converter = Converter{}
 		return
 	}
 
+	result.Explanation, err = converter.explainPlan(input, plan)
+	if err != nil {
+		result = ConversionResult{}
+	}
+
 	return
 }
+
+// ConvertQuantity works as Convert but selects the to unit from Converter.PreferredUnits when to is empty, and reports the conversion path and warnings
+func (converter *Converter) ConvertQuantity(input Quantity, to string) (result ConversionResult, err error) {
+	return converter.applyPlan(input, converter.planConversion(input.Unit, to))
+}
+
+// NewConverterFromYAML is used to parse and verify YAML data into a Converter, include is rejected as the data does not come from a file, use LoadConverter for configuration files that include others
+func NewConverterFromYAML(raw []byte) (converter Converter, err error) {
+	loader := newConfigLoader(LoadOptions{})
+	loader.loadDocument(raw, "")
+
+	return loader.result()
+}
@@ -1,7 +1,10 @@
-package main
+package unitconversion
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -38,6 +41,20 @@ func TestConverterConvert(test *testing.T) {
 	assert.Equal(test, expectedOutputCached, outputCached)
 }
 
+func TestConverterConvertWithSameUnit(test *testing.T) {
+	converter := Converter{
+		Conversions: []Conversion{
+			Conversion{From: "m", To: "km", Formula: "magnitude / 1000"},
+			Conversion{From: "km", To: "m", Formula: "magnitude * 1000"},
+		},
+	}
+
+	input := Quantity{Magnitude: 0.1, Unit: "m"}
+	output, err := converter.Convert(input, "m")
+	assert.NoError(test, err)
+	assert.Equal(test, input, output)
+}
+
 func TestFailConversionConverterConvertWithMissingConversion(test *testing.T) {
 	raw, err := ioutil.ReadFile("converter.yml")
 	assert.NoError(test, err)
@@ -339,3 +356,163 @@ func TestFailConvertToPreferredUnitWithMissingConversion(test *testing.T) {
 	assert.Error(test, err)
 	assert.Equal(test, expectedOutput, output)
 }
+
+func TestConverterConvertQuantity(test *testing.T) {
+	converter := Converter{
+		PreferredUnits: []string{"m"},
+		Conversions: []Conversion{
+			Conversion{From: "cm", To: "m", Formula: "magnitude / 100"},
+			Conversion{From: "m", To: "km", Formula: "magnitude / 1000"},
+		},
+	}
+
+	output, err := converter.ConvertQuantity(Quantity{Magnitude: 1000, Unit: "cm"}, "km")
+	assert.NoError(test, err)
+	assert.Equal(test, ConversionResult{
+		Quantity: Quantity{Magnitude: 0.01, Unit: "km"},
+		Path:     []string{"cm", "m", "km"},
+		Warnings: []string{},
+	}, output)
+
+	output, err = converter.ConvertQuantity(Quantity{Magnitude: 1e17, Unit: "cm"}, "")
+	assert.NoError(test, err)
+	assert.Equal(test, Quantity{Magnitude: 1e15, Unit: "m"}, output.Quantity)
+	assert.Len(test, output.Warnings, 2)
+}
+
+func TestFailConverterConvertQuantityWithMissingConversion(test *testing.T) {
+	converter := Converter{
+		PreferredUnits: []string{"m"},
+		Conversions: []Conversion{
+			Conversion{From: "cm", To: "m", Formula: "magnitude / 100"},
+		},
+	}
+
+	output, err := converter.ConvertQuantity(Quantity{Magnitude: 1, Unit: "g"}, "")
+	assert.Error(test, err)
+	assert.Equal(test, ConversionResult{}, output)
+
+	output, err = converter.ConvertQuantity(Quantity{Magnitude: 1, Unit: "m"}, "cm")
+	assert.Error(test, err)
+	assert.Equal(test, ConversionResult{}, output)
+}
+
+func TestConverterConvertWithCachedPaths(test *testing.T) {
+	converter := Converter{
+		Conversions: []Conversion{
+			Conversion{From: "cm", To: "m", Formula: "magnitude / 100"},
+			Conversion{From: "m", To: "dm", Formula: "magnitude * 10"},
+			Conversion{From: "dm", To: "mm", Formula: "magnitude * 100"},
+		},
+	}
+
+	output, err := converter.Convert(Quantity{Magnitude: 100, Unit: "cm"}, "mm")
+	assert.NoError(test, err)
+	assert.Equal(test, Quantity{Magnitude: 1000, Unit: "mm"}, output)
+
+	output, err = converter.Convert(Quantity{Magnitude: 1, Unit: "m"}, "mm")
+	assert.NoError(test, err)
+	assert.Equal(test, Quantity{Magnitude: 1000, Unit: "mm"}, output)
+
+	output, err = converter.Convert(Quantity{Magnitude: 100, Unit: "cm"}, "mm")
+	assert.NoError(test, err)
+	assert.Equal(test, Quantity{Magnitude: 1000, Unit: "mm"}, output)
+}
+
+func TestConverterConvertExplained(test *testing.T) {
+	converter := Converter{
+		Conversions: []Conversion{
+			Conversion{From: "cm", To: "m", Formula: "magnitude / 100"},
+			Conversion{From: "m", To: "km", Formula: "magnitude / 1000"},
+		},
+	}
+
+	output, err := converter.ConvertExplained(Quantity{Magnitude: 1000, Unit: "cm"}, "km")
+	assert.NoError(test, err)
+	assert.Equal(test, Quantity{Magnitude: 0.01, Unit: "km"}, output.Quantity)
+	assert.Equal(test, &Explanation{
+		Hops: []ConversionHop{
+			ConversionHop{From: "cm", To: "m", Formula: "magnitude / 100", Magnitude: 10},
+			ConversionHop{From: "m", To: "km", Formula: "magnitude / 1000", Magnitude: 0.01},
+		},
+		Cached: false,
+	}, output.Explanation)
+
+	output, err = converter.ConvertExplained(Quantity{Magnitude: 1000, Unit: "cm"}, "km")
+	assert.NoError(test, err)
+	assert.Equal(test, true, output.Explanation.Cached)
+
+	output, err = converter.ConvertExplained(Quantity{Magnitude: 5, Unit: "m"}, "m")
+	assert.NoError(test, err)
+	assert.Equal(test, &Explanation{Hops: []ConversionHop{}}, output.Explanation)
+}
+
+func TestFailConverterConvertExplainedWithMissingConversion(test *testing.T) {
+	converter := Converter{
+		Conversions: []Conversion{
+			Conversion{From: "cm", To: "m", Formula: "magnitude / 100"},
+		},
+	}
+
+	output, err := converter.ConvertExplained(Quantity{Magnitude: 1, Unit: "m"}, "cm")
+	assert.Error(test, err)
+	assert.Equal(test, ConversionResult{}, output)
+}
+
+func TestConversionWithImplementation(test *testing.T) {
+	converter := Converter{PreferredUnits: []string{"cm"}}
+	assert.NoError(test, converter.AddConversion(Conversion{From: "m", To: "cm", Formula: "magnitude * 100", TestFixtures: []ConversionTestFixture{{Input: 1, Expected: 100}}}))
+	assert.NoError(test, converter.AddConversion(Conversion{
+		From: "ft",
+		To:   "m",
+		Implementation: ConversionFunc(func(magnitude float64) (float64, error) {
+			return magnitude * 0.3048, nil
+		}),
+		TestFixtures: []ConversionTestFixture{{Input: 10, Expected: 3.048}},
+	}))
+
+	result, err := converter.ConvertExplained(Quantity{Magnitude: 10, Unit: "ft"}, "cm")
+	assert.NoError(test, err)
+	assert.Equal(test, Quantity{Magnitude: 304.8, Unit: "cm"}, result.Quantity)
+	assert.Equal(test, []string{"ft", "m", "cm"}, result.Path)
+	assert.Len(test, result.Explanation.Hops, 2)
+	assert.Equal(test, "go func", result.Explanation.Hops[0].Formula)
+	assert.Equal(test, "magnitude * 100", result.Explanation.Hops[1].Formula)
+
+	jsonConverter := NewJSONConverter(converter)
+	output, errs := jsonConverter.ConvertToPreferredUnits(`{"height": {"magnitude": 10, "unit": "ft"}}`)
+	assert.Empty(test, errs)
+	assert.JSONEq(test, `{"height": {"magnitude": 304.8, "unit": "cm"}}`, output)
+}
+
+func TestFailConversionWithNonFiniteResult(test *testing.T) {
+	conversion := Conversion{From: "m", To: "cm", Formula: "magnitude * 100"}
+	_, err := conversion.Convert(Quantity{Magnitude: 1e308, Unit: "m"})
+	assert.True(test, errors.Is(err, ErrFormula))
+	assert.Contains(test, err.Error(), "The result +Inf is not a finite number")
+
+	conversion = Conversion{From: "m", To: "cm", Implementation: ConversionFunc(func(magnitude float64) (float64, error) {
+		return math.NaN(), nil
+	})}
+	_, err = conversion.Convert(Quantity{Magnitude: 1, Unit: "m"})
+	assert.True(test, errors.Is(err, ErrFormula))
+}
+
+func TestFailConversionWithImplementation(test *testing.T) {
+	conversion := Conversion{
+		From: "ft",
+		To:   "m",
+		Implementation: ConversionFunc(func(magnitude float64) (float64, error) {
+			return magnitude * 0.3, nil
+		}),
+		TestFixtures: []ConversionTestFixture{{Input: 10, Expected: 3.048}},
+	}
+	assert.True(test, errors.Is(conversion.Test(), ErrFixtureFailed))
+
+	conversion.Implementation = ConversionFunc(func(magnitude float64) (float64, error) {
+		return 0, fmt.Errorf("The magnitude is out of range")
+	})
+	_, err := conversion.Convert(Quantity{Magnitude: 10, Unit: "ft"})
+	assert.True(test, errors.Is(err, ErrFormula))
+	assert.Equal(test, `The conversion from "ft" to "m" failed for the magnitude 10: The magnitude is out of range`, err.Error())
+}
@@ -0,0 +1,32 @@
+package unitconversion
+
+import _ "embed" // for go:embed
+
+// defaultLibraryFile is the name used for the default unit library in error messages
+const defaultLibraryFile = "defaults/units.yml"
+
+// defaultLibrary is the default unit library, configuration files are loaded on top of it
+//
+//go:embed defaults/units.yml
+var defaultLibrary []byte
+
+// loadDefaults applies the default unit library, units and conversions from it can be replaced by later files without override: true
+func (loader *configLoader) loadDefaults() {
+	loader.builtin = true
+	loader.loadDocument(defaultLibrary, defaultLibraryFile)
+	loader.builtin = false
+}
+
+// DefaultConverter returns a Converter with only the default unit library that is embedded in the binary
+func DefaultConverter() (converter Converter, err error) {
+	converter, _, err = LoadConverterFiles(LoadOptions{Defaults: true})
+
+	return
+}
+
+// LoadConverterWithDefaults works as LoadConverter but loads the configuration on top of the default unit library, without paths only the default unit library is used
+func LoadConverterWithDefaults(paths ...string) (converter Converter, err error) {
+	converter, _, err = LoadConverterFiles(LoadOptions{Defaults: true}, paths...)
+
+	return
+}
@@ -0,0 +1,83 @@
+package unitconversion
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultConverter(test *testing.T) {
+	converter, err := DefaultConverter()
+	assert.NoError(test, err)
+	assert.Empty(test, converter.Lint(DefaultLintTolerance))
+
+	for _, example := range []struct {
+		input    Quantity
+		to       string
+		expected float64
+	}{
+		{Quantity{Magnitude: 1, Unit: "mi"}, "km", 1.609344},
+		{Quantity{Magnitude: 212, Unit: "°F"}, "K", 373.15},
+		{Quantity{Magnitude: 1, Unit: "gal"}, "ml", 3785.411784},
+		{Quantity{Magnitude: 100, Unit: "km/h"}, "mph", 62.13711922373339},
+		{Quantity{Magnitude: 1, Unit: "kWh"}, "kcal", 860.4206500956023},
+		{Quantity{Magnitude: 1, Unit: "mg/dl"}, "µg/l", 10000},
+	} {
+		output, err := converter.Convert(example.input, example.to)
+		assert.NoError(test, err)
+		assert.InEpsilon(test, example.expected, output.Magnitude, 1e-12, "%v to %s", example.input, example.to)
+	}
+
+	output, err := converter.ConvertToPreferredUnit(Quantity{Magnitude: 2, Unit: "h"})
+	assert.NoError(test, err)
+	assert.Equal(test, Quantity{Magnitude: 7200, Unit: "s"}, output)
+}
+
+func TestLoadConverterWithDefaults(test *testing.T) {
+	directory := writeConfigFiles(test, map[string]string{
+		"lab.yml": `preferredUnits:
+  - kg
+units:
+  - name: m
+    dimension: distance
+conversions:
+  - from: g
+    to: kg
+    formula: magnitude / 1000
+    testFixtures:
+      - input: 1000
+        expected: 1
+  - from: mmol/l
+    to: mg/dl
+    formula: magnitude * 18
+    testFixtures:
+      - input: 1
+        expected: 18
+`,
+	})
+	defer os.RemoveAll(directory)
+
+	converter, err := LoadConverterWithDefaults(filepath.Join(directory, "lab.yml"))
+	assert.NoError(test, err)
+
+	output, err := converter.ConvertToPreferredUnit(Quantity{Magnitude: 500, Unit: "lb"})
+	assert.NoError(test, err)
+	assert.Equal(test, "kg", output.Unit)
+
+	output, err = converter.Convert(Quantity{Magnitude: 5, Unit: "mmol/l"}, "g/l")
+	assert.NoError(test, err)
+	assert.InEpsilon(test, 0.9, output.Magnitude, 1e-12)
+
+	definition, _ := converter.unitDefinition("m")
+	assert.Equal(test, "distance", definition.Dimension)
+
+	defaultConverter, err := DefaultConverter()
+	assert.NoError(test, err)
+	assert.Len(test, converter.Conversions, len(defaultConverter.Conversions)+1)
+
+	converter, err = LoadConverter(filepath.Join(directory, "lab.yml"))
+	assert.NoError(test, err)
+	assert.Len(test, converter.Conversions, 2)
+}
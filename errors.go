@@ -0,0 +1,204 @@
+package unitconversion
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error codes used by QuantityError, they are stable and meant to be matched by clients
+const (
+	ErrorCodeInvalidJSON       = "invalid_json"
+	ErrorCodeNoPreferredUnit   = "no_preferred_unit"
+	ErrorCodeConversionFailed  = "conversion_failed"
+	ErrorCodeWriteFailed       = "write_failed"
+	ErrorCodeInvalidAnnotation = "invalid_annotation"
+	ErrorCodeInvalidRequest    = "invalid_request"
+	ErrorCodeUnknownUnit       = "unknown_unit"
+	ErrorCodeNoPath            = "no_path"
+	ErrorCodeUnitMismatch      = "unit_mismatch"
+	ErrorCodeFormulaFailed     = "formula_failed"
+	ErrorCodeFixtureFailed     = "fixture_failed"
+	ErrorCodePrecisionLost     = "precision_lost"
+)
+
+// Sentinel errors for conversion failures, match them with errors.Is. The errors that are returned are the types below, use errors.As to get their details
+var (
+	ErrUnknownUnit     = errors.New("unknown unit")
+	ErrNoPath          = errors.New("no conversion path")
+	ErrNoPreferredUnit = errors.New("no preferred unit")
+	ErrUnitMismatch    = errors.New("unit mismatch")
+	ErrFormula         = errors.New("formula failed")
+	ErrFixtureFailed   = errors.New("test fixture failed")
+)
+
+// ErrConversionNotDefined is matched by the error that Converter.ReplaceConversion and Converter.RemoveConversion return when there is no conversion in between the units, it is only returned from Go so it has no error code
+var ErrConversionNotDefined = errors.New("conversion not defined")
+
+// errorCodes is the error code of each sentinel error
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrUnknownUnit, ErrorCodeUnknownUnit},
+	{ErrNoPath, ErrorCodeNoPath},
+	{ErrNoPreferredUnit, ErrorCodeNoPreferredUnit},
+	{ErrUnitMismatch, ErrorCodeUnitMismatch},
+	{ErrFormula, ErrorCodeFormulaFailed},
+	{ErrFixtureFailed, ErrorCodeFixtureFailed},
+}
+
+// ErrorCodeOf returns the error code for one of the sentinel errors above, other errors get ErrorCodeConversionFailed
+func ErrorCodeOf(err error) string {
+	for _, errorCode := range errorCodes {
+		if errors.Is(err, errorCode.err) {
+			return errorCode.code
+		}
+	}
+
+	return ErrorCodeConversionFailed
+}
+
+// UnknownUnitError is returned for a unit that is neither used by a conversion nor defined under units, it matches ErrUnknownUnit
+type UnknownUnitError struct {
+	Unit string
+}
+
+func (unknownUnitError *UnknownUnitError) Error() string {
+	return fmt.Sprintf("The unit %q is not known", unknownUnitError.Unit)
+}
+
+// Is makes errors.Is match ErrUnknownUnit
+func (unknownUnitError *UnknownUnitError) Is(target error) bool {
+	return target == ErrUnknownUnit
+}
+
+// NoPathError is returned when no chain of conversions leads from one known unit to another, it matches ErrNoPath
+type NoPathError struct {
+	From string
+	To   string
+}
+
+func (noPathError *NoPathError) Error() string {
+	return fmt.Sprintf("Unable to find a path from %q to %q", noPathError.From, noPathError.To)
+}
+
+// Is makes errors.Is match ErrNoPath
+func (noPathError *NoPathError) Is(target error) bool {
+	return target == ErrNoPath
+}
+
+// NoPreferredUnitError is returned when none of Converter.PreferredUnits can be reached from Unit, it matches ErrNoPreferredUnit
+type NoPreferredUnitError struct {
+	Unit string
+}
+
+func (noPreferredUnitError *NoPreferredUnitError) Error() string {
+	return fmt.Sprintf("Unable to find a preferred unit for %q, conversion not possible", noPreferredUnitError.Unit)
+}
+
+// Is makes errors.Is match ErrNoPreferredUnit
+func (noPreferredUnitError *NoPreferredUnitError) Is(target error) bool {
+	return target == ErrNoPreferredUnit
+}
+
+// UnitMismatchError is returned when a Conversion is given a Quantity with another unit than Conversion.From, it matches ErrUnitMismatch
+type UnitMismatchError struct {
+	Expected string
+	Actual   string
+}
+
+func (unitMismatchError *UnitMismatchError) Error() string {
+	return fmt.Sprintf("Conversion from unit mismatch got %q but expected %q", unitMismatchError.Actual, unitMismatchError.Expected)
+}
+
+// Is makes errors.Is match ErrUnitMismatch
+func (unitMismatchError *UnitMismatchError) Is(target error) bool {
+	return target == ErrUnitMismatch
+}
+
+// FormulaError is returned when the formula of a Conversion can not be parsed or evaluated for Input, or when its Conversion.Implementation fails, Err is the cause and it matches ErrFormula
+type FormulaError struct {
+	From    string
+	To      string
+	Formula string
+	Input   float64
+	Err     error
+}
+
+func (formulaError *FormulaError) Error() string {
+	if formulaError.Formula == "" {
+		return fmt.Sprintf("The conversion from %q to %q failed for the magnitude %v: %v", formulaError.From, formulaError.To, formulaError.Input, formulaError.Err)
+	}
+
+	return fmt.Sprintf("The formula %q from %q to %q failed for the magnitude %v: %v", formulaError.Formula, formulaError.From, formulaError.To, formulaError.Input, formulaError.Err)
+}
+
+// Is makes errors.Is match ErrFormula
+func (formulaError *FormulaError) Is(target error) bool {
+	return target == ErrFormula
+}
+
+func (formulaError *FormulaError) Unwrap() error {
+	return formulaError.Err
+}
+
+// FixtureError is returned by Conversion.Test when a test fixture does not give the expected magnitude, it matches ErrFixtureFailed
+type FixtureError struct {
+	From     string
+	To       string
+	Formula  string
+	Input    float64
+	Expected float64
+	Actual   float64
+}
+
+func (fixtureError *FixtureError) Error() string {
+	return fmt.Sprintf("Conversion test failed, from %q to %q with formula %q and input %f expected %f but got %f", fixtureError.From, fixtureError.To, fixtureError.Formula, fixtureError.Input, fixtureError.Expected, fixtureError.Actual)
+}
+
+// Is makes errors.Is match ErrFixtureFailed
+func (fixtureError *FixtureError) Is(target error) bool {
+	return target == ErrFixtureFailed
+}
+
+// ConversionNotDefinedError is returned when a conversion from From to To is changed but not defined, it matches ErrConversionNotDefined
+type ConversionNotDefinedError struct {
+	From string
+	To   string
+}
+
+func (conversionNotDefinedError *ConversionNotDefinedError) Error() string {
+	return fmt.Sprintf("The conversion from %q to %q is not defined", conversionNotDefinedError.From, conversionNotDefinedError.To)
+}
+
+// Is makes errors.Is match ErrConversionNotDefined
+func (conversionNotDefinedError *ConversionNotDefinedError) Is(target error) bool {
+	return target == ErrConversionNotDefined
+}
+
+// QuantityError describes why a quantity at a path in a JSON document could not be converted
+type QuantityError struct {
+	Path    string `json:"path"`
+	Unit    string `json:"unit,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Err     error  `json:"-"`
+}
+
+// NewQuantityError creates a QuantityError for the quantity at path with one of the ErrorCode constants
+func NewQuantityError(path string, unit string, code string, err error) *QuantityError {
+	return &QuantityError{Path: path, Unit: unit, Code: code, Message: err.Error(), Err: err}
+}
+
+// Unwrap returns the cause, so that errors.Is and errors.As find the sentinel errors and typed errors above through a QuantityError
+func (quantityError *QuantityError) Unwrap() error {
+	return quantityError.Err
+}
+
+func (quantityError *QuantityError) Error() string {
+	if quantityError.Path == "" {
+		return fmt.Sprintf("%s: %s", quantityError.Code, quantityError.Message)
+	}
+
+	return fmt.Sprintf("%s at %q: %s", quantityError.Code, quantityError.Path, quantityError.Message)
+}
@@ -0,0 +1,91 @@
+package unitconversion
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConversionErrors(test *testing.T) {
+	converter := Converter{
+		Conversions: []Conversion{
+			Conversion{From: "in", To: "m", Formula: "magnitude * 0.0254"},
+			Conversion{From: "g", To: "kg", Formula: "magnitude / 1000"},
+			Conversion{From: "kg", To: "g", Formula: "magnitude +"},
+		},
+	}
+
+	_, err := converter.Convert(Quantity{Magnitude: 1, Unit: "in"}, "kg")
+	assert.True(test, errors.Is(err, ErrNoPath))
+	assert.Equal(test, ErrorCodeNoPath, ErrorCodeOf(err))
+	var noPathError *NoPathError
+	assert.True(test, errors.As(err, &noPathError))
+	assert.Equal(test, NoPathError{From: "in", To: "kg"}, *noPathError)
+
+	_, err = converter.Convert(Quantity{Magnitude: 1, Unit: "parsec"}, "m")
+	assert.True(test, errors.Is(err, ErrUnknownUnit))
+	assert.False(test, errors.Is(err, ErrNoPath))
+	assert.EqualError(test, err, `The unit "parsec" is not known`)
+
+	_, err = converter.ConvertToPreferredUnit(Quantity{Magnitude: 1, Unit: "in"})
+	assert.True(test, errors.Is(err, ErrNoPreferredUnit))
+	assert.Equal(test, ErrorCodeNoPreferredUnit, ErrorCodeOf(err))
+
+	_, err = converter.Convert(Quantity{Magnitude: 1, Unit: "kg"}, "g")
+	assert.True(test, errors.Is(err, ErrFormula))
+	var formulaError *FormulaError
+	assert.True(test, errors.As(err, &formulaError))
+	assert.Equal(test, "magnitude +", formulaError.Formula)
+	assert.Equal(test, float64(1), formulaError.Input)
+	assert.Error(test, errors.Unwrap(err))
+
+	_, err = converter.Conversions[0].Convert(Quantity{Magnitude: 1, Unit: "ft"})
+	assert.True(test, errors.Is(err, ErrUnitMismatch))
+	assert.EqualError(test, err, `Conversion from unit mismatch got "ft" but expected "in"`)
+
+	assert.Equal(test, ErrorCodeConversionFailed, ErrorCodeOf(errors.New("something else")))
+}
+
+func TestQuantityErrorUnwrap(test *testing.T) {
+	converter := NewJSONConverter(Converter{
+		PreferredUnits: []string{"m"},
+		Conversions:    []Conversion{Conversion{From: "in", To: "m", Formula: "magnitude * 0.0254"}},
+	})
+
+	_, errs := converter.ConvertToPreferredUnits(`{"a": {"magnitude": 1, "unit": "parsec"}, "b": {"magnitude": 1, "unit": "g"}}`)
+	assert.Len(test, errs, 2)
+	for _, err := range errs {
+		assert.True(test, errors.Is(err, ErrNoPreferredUnit), err.Error())
+		var noPreferredUnitError *NoPreferredUnitError
+		assert.True(test, errors.As(err, &noPreferredUnitError), err.Error())
+		var quantityError *QuantityError
+		assert.True(test, errors.As(err, &quantityError))
+		assert.Equal(test, ErrorCodeNoPreferredUnit, ErrorCodeOf(err))
+	}
+
+	err := NewQuantityError("a", "in", ErrorCodeNoPath, &NoPathError{From: "in", To: "kg"})
+	var noPathError *NoPathError
+	assert.True(test, errors.As(err, &noPathError))
+	assert.Equal(test, "kg", noPathError.To)
+	assert.False(test, errors.Is(err, ErrFormula))
+}
+
+func TestFailConversionTestWithFixtureError(test *testing.T) {
+	conversion := Conversion{From: "m", To: "cm", Formula: "magnitude * 10", TestFixtures: []ConversionTestFixture{{Input: 1, Expected: 100}}}
+
+	err := conversion.Test()
+	assert.True(test, errors.Is(err, ErrFixtureFailed))
+	assert.Equal(test, ErrorCodeFixtureFailed, ErrorCodeOf(err))
+	var fixtureError *FixtureError
+	assert.True(test, errors.As(err, &fixtureError))
+	assert.Equal(test, FixtureError{From: "m", To: "cm", Formula: "magnitude * 10", Input: 1, Expected: 100, Actual: 10}, *fixtureError)
+}
+
+func TestFailConversionWithFormulaThatIsNotANumber(test *testing.T) {
+	conversion := Conversion{From: "m", To: "cm", Formula: "magnitude > 1"}
+
+	_, err := conversion.Convert(Quantity{Magnitude: 2, Unit: "m"})
+	assert.True(test, errors.Is(err, ErrFormula))
+	assert.EqualError(test, err, `The formula "magnitude > 1" from "m" to "cm" failed for the magnitude 2: The result true is not a number`)
+}
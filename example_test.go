@@ -0,0 +1,109 @@
+package unitconversion_test
+
+import (
+	"fmt"
+
+	unitconversion "github.com/tirithen/unit-conversion"
+)
+
+func Example() {
+	converter, err := unitconversion.NewConverterFromYAML([]byte(`
+preferredUnits:
+  - cm
+conversions:
+  - from: in
+    to: cm
+    formula: magnitude * 2.54
+    testFixtures:
+      - input: 1
+        expected: 2.54
+`))
+	if err != nil {
+		panic(err)
+	}
+
+	output, err := converter.Convert(unitconversion.Quantity{Magnitude: 10, Unit: "in"}, "cm")
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(output.Magnitude, output.Unit)
+	// Output: 25.4 cm
+}
+
+func ExampleDefaultConverter() {
+	converter, err := unitconversion.DefaultConverter()
+	if err != nil {
+		panic(err)
+	}
+
+	result, err := converter.ConvertQuantity(unitconversion.Quantity{Magnitude: 212, Unit: "°F"}, "")
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(result.Quantity.Magnitude, result.Quantity.Unit)
+	// Output: 100 °C
+}
+
+func ExampleConverter_FindPath() {
+	converter, err := unitconversion.DefaultConverter()
+	if err != nil {
+		panic(err)
+	}
+
+	path, err := converter.FindPath("inch", "ft")
+	if err != nil {
+		panic(err)
+	}
+
+	for _, conversion := range path {
+		fmt.Printf("%s -> %s: %s\n", conversion.From, conversion.To, conversion.Formula)
+	}
+	// Output:
+	// in -> m: magnitude * 0.0254
+	// m -> ft: magnitude / 0.3048
+}
+
+func ExampleJSONConverter_ConvertToPreferredUnits() {
+	converter, err := unitconversion.DefaultConverter()
+	if err != nil {
+		panic(err)
+	}
+
+	jsonConverter := unitconversion.NewJSONConverter(converter)
+	output, errors := jsonConverter.ConvertToPreferredUnits(`{"model": "Supertablet", "size": {"magnitude": 10, "unit": "in"}, "weight": {"magnitude": 1, "unit": "parsec"}}`)
+
+	fmt.Println(output)
+	for _, err := range errors {
+		fmt.Println(err)
+	}
+	// Output:
+	// {"model": "Supertablet", "size": {"magnitude": 0.254, "unit": "m"}, "weight": {"magnitude": 1, "unit": "parsec"}}
+	// no_preferred_unit at "weight": Unable to find a preferred unit for "parsec", conversion not possible
+}
+
+func ExampleConverter_AddConversion() {
+	converter := unitconversion.Converter{}
+	err := converter.AddConversion(unitconversion.Conversion{
+		From:         "ft",
+		To:           "in",
+		Formula:      "magnitude * 12",
+		TestFixtures: []unitconversion.ConversionTestFixture{{Input: 1, Expected: 12}},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	if err = converter.SetPreferredUnits("in"); err != nil {
+		panic(err)
+	}
+
+	output, err := converter.ConvertToPreferredUnit(unitconversion.Quantity{Magnitude: 3, Unit: "ft"})
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(output.Magnitude, output.Unit)
+	// Output: 36 in
+}
@@ -0,0 +1,54 @@
+package unitconversion
+
+import "encoding/json"
+
+// FHIRUCUMSystem is the code system URI that HL7 FHIR uses for quantities coded with UCUM
+const FHIRUCUMSystem = "http://unitsofmeasure.org"
+
+// FHIRQuantity holds the properties of a HL7 FHIR Quantity element that takes part in a conversion, other properties such as comparator and extension are never modified
+type FHIRQuantity struct {
+	Value  float64 `json:"value"`
+	Unit   string  `json:"unit,omitempty"`
+	System string  `json:"system,omitempty"`
+	Code   string  `json:"code"`
+}
+
+// Quantity returns the FHIRQuantity as a Quantity using the code as unit
+func (fhirQuantity FHIRQuantity) Quantity() Quantity {
+	return Quantity{Magnitude: fhirQuantity.Value, Unit: fhirQuantity.Code}
+}
+
+func fhirQuantityFromNode(node mapNode, acceptStrings bool) (fhirQuantity FHIRQuantity, quoted bool, ok bool) {
+	rawValue, hasValue := node["value"]
+	rawCode, hasCode := node["code"]
+	if !hasValue || !hasCode {
+		return
+	}
+
+	value, quoted, isNumber := parseMagnitude(rawValue, acceptStrings)
+	if !isNumber {
+		return
+	}
+
+	if json.Unmarshal(rawCode, &fhirQuantity.Code) != nil || fhirQuantity.Code == "" {
+		return
+	}
+
+	if rawSystem, hasSystem := node["system"]; hasSystem {
+		if json.Unmarshal(rawSystem, &fhirQuantity.System) != nil || fhirQuantity.System != FHIRUCUMSystem {
+			return
+		}
+	}
+
+	// The unit is replaced when the quantity is converted, so it has to be a string
+	if rawUnit, hasUnit := node["unit"]; hasUnit {
+		if json.Unmarshal(rawUnit, &fhirQuantity.Unit) != nil {
+			return
+		}
+	}
+
+	fhirQuantity.Value = value
+	ok = true
+
+	return
+}
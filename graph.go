@@ -0,0 +1,95 @@
+package unitconversion
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// GraphFormat selects the output format of Converter.Graph
+type GraphFormat string
+
+const (
+	// GraphFormatDOT is the Graphviz DOT language, e.g. render with dot -Tsvg
+	GraphFormatDOT GraphFormat = "dot"
+	// GraphFormatMermaid is a Mermaid flowchart, which can be embedded in Markdown
+	GraphFormatMermaid GraphFormat = "mermaid"
+)
+
+func (converter *Converter) isPreferredUnit(unit string) bool {
+	return containsString(converter.PreferredUnits, unit)
+}
+
+// Graph exports the units and conversions as a graph where each conversion is an edge labelled with its formula and preferred units are highlighted
+func (converter *Converter) Graph(format GraphFormat) (graph string, err error) {
+	switch format {
+	case GraphFormatDOT:
+		graph = converter.GraphDOT()
+	case GraphFormatMermaid:
+		graph = converter.GraphMermaid()
+	default:
+		err = fmt.Errorf("Unknown graph format %q, use %q or %q", format, GraphFormatDOT, GraphFormatMermaid)
+	}
+
+	return
+}
+
+func dotQuote(text string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text) + `"`
+}
+
+// GraphDOT exports the conversion graph in the Graphviz DOT language
+func (converter *Converter) GraphDOT() string {
+	var graph bytes.Buffer
+	graph.WriteString("digraph conversions {\n")
+	graph.WriteString("  rankdir=LR;\n")
+
+	for _, unit := range converter.unitNames() {
+		if converter.isPreferredUnit(unit) {
+			fmt.Fprintf(&graph, "  %s [style=filled, fillcolor=lightblue, penwidth=2];\n", dotQuote(unit))
+		} else {
+			fmt.Fprintf(&graph, "  %s;\n", dotQuote(unit))
+		}
+	}
+
+	for index := range converter.Conversions {
+		conversion := &converter.Conversions[index]
+		fmt.Fprintf(&graph, "  %s -> %s [label=%s];\n", dotQuote(conversion.From), dotQuote(conversion.To), dotQuote(conversion.Description()))
+	}
+
+	graph.WriteString("}\n")
+
+	return graph.String()
+}
+
+func mermaidQuote(text string) string {
+	return `"` + strings.NewReplacer(`"`, "#quot;").Replace(text) + `"`
+}
+
+// GraphMermaid exports the conversion graph as a Mermaid flowchart, units get the node ids u0, u1 and so on as unit names can not be used as ids
+func (converter *Converter) GraphMermaid() string {
+	var graph bytes.Buffer
+	graph.WriteString("flowchart LR\n")
+
+	ids := map[string]string{}
+	preferred := []string{}
+	for index, unit := range converter.unitNames() {
+		ids[unit] = fmt.Sprintf("u%d", index)
+		fmt.Fprintf(&graph, "  %s[%s]\n", ids[unit], mermaidQuote(unit))
+		if converter.isPreferredUnit(unit) {
+			preferred = append(preferred, ids[unit])
+		}
+	}
+
+	for index := range converter.Conversions {
+		conversion := &converter.Conversions[index]
+		fmt.Fprintf(&graph, "  %s -->|%s| %s\n", ids[conversion.From], mermaidQuote(conversion.Description()), ids[conversion.To])
+	}
+
+	if len(preferred) > 0 {
+		graph.WriteString("  classDef preferred fill:#add8e6,stroke-width:2px\n")
+		fmt.Fprintf(&graph, "  class %s preferred\n", strings.Join(preferred, ","))
+	}
+
+	return graph.String()
+}
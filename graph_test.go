@@ -0,0 +1,65 @@
+package unitconversion
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newGraphTestConverter() Converter {
+	return Converter{
+		PreferredUnits: []string{"m"},
+		Conversions: []Conversion{
+			Conversion{From: "cm", To: "m", Formula: "magnitude / 100"},
+			Conversion{From: "m", To: "cm", Formula: "magnitude * 100"},
+			Conversion{From: "µg/l", To: "ng/ml", Formula: "magnitude"},
+		},
+	}
+}
+
+func TestConverterGraphDOT(test *testing.T) {
+	converter := newGraphTestConverter()
+
+	assert.Equal(test, `digraph conversions {
+  rankdir=LR;
+  "cm";
+  "m" [style=filled, fillcolor=lightblue, penwidth=2];
+  "ng/ml";
+  "µg/l";
+  "cm" -> "m" [label="magnitude / 100"];
+  "m" -> "cm" [label="magnitude * 100"];
+  "µg/l" -> "ng/ml" [label="magnitude"];
+}
+`, converter.GraphDOT())
+}
+
+func TestConverterGraphMermaid(test *testing.T) {
+	converter := newGraphTestConverter()
+
+	assert.Equal(test, `flowchart LR
+  u0["cm"]
+  u1["m"]
+  u2["ng/ml"]
+  u3["µg/l"]
+  u0 -->|"magnitude / 100"| u1
+  u1 -->|"magnitude * 100"| u0
+  u3 -->|"magnitude"| u2
+  classDef preferred fill:#add8e6,stroke-width:2px
+  class u1 preferred
+`, converter.GraphMermaid())
+}
+
+func TestConverterGraphEscapesQuotes(test *testing.T) {
+	converter := Converter{Conversions: []Conversion{Conversion{From: `in"`, To: "m", Formula: "magnitude * 0.0254"}}}
+
+	assert.Contains(test, converter.GraphDOT(), `"in\"" -> "m"`)
+	assert.Contains(test, converter.GraphMermaid(), `u0["in#quot;"]`)
+}
+
+func TestFailConverterGraphWithUnknownFormat(test *testing.T) {
+	converter := newGraphTestConverter()
+
+	graph, err := converter.Graph(GraphFormat("svg"))
+	assert.Error(test, err)
+	assert.Empty(test, graph)
+}
@@ -1,106 +1,332 @@
-package main
+package unitconversion
 
 import (
+	"bytes"
 	"encoding/json"
-	"strconv"
+	"fmt"
+	"io"
 	"strings"
-
-	"github.com/tidwall/sjson"
 )
 
 type mapNode map[string]json.RawMessage
-type arrayNode []json.RawMessage
 
-// JSONConverter works much as Converter but is specalized for converting quantity structures (magnitude/unit pairs) in JSON trees with the ConvertToPreferredUnits method
+const (
+	// annotationOriginalProperty is the property that holds the original quantity in annotated output
+	annotationOriginalProperty = "original"
+	// annotationConversionProperty is the property that holds the provenance of a conversion in annotated output
+	annotationConversionProperty = "conversion"
+)
+
+// conversionProperties are the properties that a conversion without annotations reads or writes, they are watched by the jsonStreamRewriter
+var conversionProperties = map[string]bool{
+	"magnitude": true,
+	"unit":      true,
+	"value":     true,
+	"code":      true,
+	"system":    true,
+}
+
+// quantityProperties are the properties that a conversion with annotations or a revert reads or writes, they are watched by the jsonStreamRewriter
+var quantityProperties = map[string]bool{
+	"magnitude":                  true,
+	"unit":                       true,
+	"value":                      true,
+	"code":                       true,
+	"system":                     true,
+	annotationOriginalProperty:   true,
+	annotationConversionProperty: true,
+}
+
+// annotationProperties holds values that are never converted themselves
+var annotationProperties = map[string]bool{
+	annotationOriginalProperty:   true,
+	annotationConversionProperty: true,
+}
+
+// preferredTarget is the preferred unit selected for a unit, remembered for the rest of a document
+type preferredTarget struct {
+	unit string
+	err  error
+}
+
+// ConversionAnnotation describes how an annotated quantity was converted
+type ConversionAnnotation struct {
+	JSONPath string                 `json:"jsonPath"`
+	Steps    []ConversionStepSource `json:"steps"`
+}
+
+// ConversionStepSource describes one Conversion that was used to convert an annotated quantity
+type ConversionStepSource struct {
+	From      string   `json:"from"`
+	To        string   `json:"to"`
+	Formula   string   `json:"formula"`
+	Magnitude *float64 `json:"magnitude,omitempty"`
+}
+
+// JSONConverter works much as Converter but is specalized for converting quantity structures (magnitude/unit pairs and UCUM coded HL7 FHIR Quantity elements) in JSON trees with the ConvertToPreferredUnits method. Magnitudes that are not converted are copied exactly as they were written, converted magnitudes are calculated as float64, so a magnitude with more significant digits than a float64 holds is left as it was and reported with ErrorCodePrecisionLost instead of being rounded
 type JSONConverter struct {
 	Converter
+	// Annotate makes ConvertToPreferredUnits keep the original quantity and the conversion provenance in each converted object, the values of original and conversion properties are then never converted. Without it they are converted as any other part of the document
+	Annotate bool
+	// Explain works as Annotate and also adds the magnitude after each step to the conversion provenance, as ConvertExplained does for a single quantity
+	Explain bool
+	// AcceptStringMagnitudes makes ConvertToPreferredUnits also convert magnitudes written as JSON strings such as "10.5", they are written back as strings
+	AcceptStringMagnitudes bool
 }
 
-func (converter *JSONConverter) walkJSON(path string, rawNode json.RawMessage, input string) (output string, errors []error) {
-	output = input
+func joinJSONPath(path string, property string) string {
+	if path == "" {
+		return property
+	}
 
-	if rawNode[0] == 123 { // 123 is `{` => object
-		var node mapNode
-		json.Unmarshal(rawNode, &node)
+	return path + "." + property
+}
 
-		quantity := Quantity{}
-		foundMagnitude := false
-		foundUnit := false
+func quoteJSON(text string) string {
+	raw, _ := json.Marshal(text)
+	return string(raw)
+}
 
-		for property, value := range node {
-			newPath := property
-			if path != "" {
-				newPath = path + "." + property
-			}
+func isAnnotated(node mapNode) bool {
+	_, hasOriginal := node[annotationOriginalProperty]
+	_, hasConversion := node[annotationConversionProperty]
 
-			subErrors := []error{}
-			output, subErrors = converter.walkJSON(newPath, value, output)
-			if len(subErrors) > 0 {
-				errors = append(errors, subErrors...)
-			}
+	return hasOriginal && hasConversion
+}
 
-			if property == "magnitude" {
-				magnitude, err := strconv.ParseFloat(string(value), 64)
-				if err == nil {
-					quantity.Magnitude = magnitude
-					foundMagnitude = true
-				}
-			} else if property == "unit" {
-				quantity.Unit = strings.Trim(string(value), "\"")
-				foundUnit = true
-			}
+// convertObject converts the magnitude/unit pair or FHIR Quantity in object if it has one, targets remembers the preferred unit for each unit seen in the document
+func (converter *JSONConverter) convertObject(path string, object *jsonObject, targets map[string]preferredTarget) (errors []error) {
+	node := object.properties()
+	quantity := Quantity{}
+	properties := []string{}
+	quoted := false
+	foundMagnitude := false
+	foundUnit := false
+
+	if value, ok := node["magnitude"]; ok {
+		quantity.Magnitude, quoted, foundMagnitude = parseMagnitude(value, converter.AcceptStringMagnitudes)
+	}
+
+	if value, ok := node["unit"]; ok {
+		foundUnit = json.Unmarshal(value, &quantity.Unit) == nil
+	}
+
+	isFHIRQuantity := false
+	if foundMagnitude && foundUnit {
+		properties = []string{"magnitude", "unit"}
+	} else if fhirQuantity, fhirQuoted, ok := fhirQuantityFromNode(node, converter.AcceptStringMagnitudes); ok {
+		quantity = fhirQuantity.Quantity()
+		quoted = fhirQuoted
+		properties = []string{"value", "unit", "code"}
+		isFHIRQuantity = true
+	} else {
+		return
+	}
+
+	target, seen := targets[quantity.Unit]
+	if !seen {
+		target.unit, target.err = converter.preferredUnit(quantity.Unit)
+		targets[quantity.Unit] = target
+	}
+
+	if target.err != nil {
+		errors = append(errors, NewQuantityError(path, quantity.Unit, ErrorCodeNoPreferredUnit, target.err))
+		return
+	}
+
+	convertedQuantity, conversionPath, err := converter.convert(quantity, target.unit)
+	if err != nil {
+		errors = append(errors, NewQuantityError(path, quantity.Unit, ErrorCodeOf(err), err))
+		return
+	}
+
+	if len(conversionPath) == 0 && convertedQuantity.Unit == quantity.Unit {
+		return
+	}
+
+	if losesPrecision(node[properties[0]], quantity.Magnitude) {
+		errors = append(errors, NewQuantityError(path, quantity.Unit, ErrorCodePrecisionLost, fmt.Errorf("The magnitude has more than %d significant digits and would be rounded to %v when converted", maxExactDigits, quantity.Magnitude)))
+		return
+	}
+
+	magnitude, err := formatMagnitude(convertedQuantity.Magnitude, quoted)
+	if err != nil {
+		errors = append(errors, NewQuantityError(path, quantity.Unit, ErrorCodeWriteFailed, err))
+		return
+	}
+
+	values := map[string]string{
+		"magnitude": magnitude,
+		"unit":      quoteJSON(convertedQuantity.Unit),
+	}
+	if isFHIRQuantity {
+		code, symbol := unitForms(convertedQuantity.Unit)
+		values = map[string]string{
+			"value": magnitude,
+			"unit":  quoteJSON(symbol),
+			"code":  quoteJSON(code),
 		}
+	}
 
-		if foundMagnitude && foundUnit {
-			convertedQuantity, err := converter.ConvertToPreferredUnit(quantity)
-
-			if err == nil {
-				output, err = sjson.Set(output, path+".magnitude", convertedQuantity.Magnitude)
-				if err != nil {
-					errors = append(errors, err)
-				}
-				output, err = sjson.Set(output, path+".unit", convertedQuantity.Unit)
-				if err != nil {
-					errors = append(errors, err)
-				}
-			} else {
-				errors = append(errors, err)
+	if converter.Annotate || converter.Explain {
+		var explanation *Explanation
+		if converter.Explain {
+			explanation, err = converter.explainPlan(quantity, conversionPlan{path: conversionPath})
+			if err != nil {
+				errors = append(errors, NewQuantityError(path, quantity.Unit, ErrorCodeOf(err), err))
+				return
 			}
 		}
-	} else if rawNode[0] == 91 { // 91 is `[` => array
-		var node arrayNode
-		json.Unmarshal(rawNode, &node)
-		for index, value := range node {
-			newPath := strconv.Itoa(index)
-			if path != "" {
-				newPath = path + "." + strconv.Itoa(index)
-			}
 
-			subErrors := []error{}
-			output, subErrors = converter.walkJSON(newPath, value, output)
-			if len(subErrors) > 0 {
-				errors = append(errors, subErrors...)
+		err = annotateObject(path, object, node, properties, conversionPath, explanation)
+		if err != nil {
+			errors = append(errors, NewQuantityError(path, quantity.Unit, ErrorCodeWriteFailed, err))
+			return
+		}
+	}
+
+	for _, property := range properties {
+		object.set(property, []byte(values[property]))
+	}
+
+	return
+}
+
+// annotateObject writes the original values of properties and the provenance of the conversion into object, an existing original is kept so that the first source survives repeated conversions. The magnitudes of explanation are added to the steps when it is not nil
+func annotateObject(path string, object *jsonObject, node mapNode, properties []string, conversionPath []*Conversion, explanation *Explanation) (err error) {
+	annotation := ConversionAnnotation{JSONPath: path, Steps: []ConversionStepSource{}}
+	for index, conversion := range conversionPath {
+		step := ConversionStepSource{
+			From:    conversion.From,
+			To:      conversion.To,
+			Formula: conversion.Description(),
+		}
+		if explanation != nil {
+			step.Magnitude = &explanation.Hops[index].Magnitude
+		}
+		annotation.Steps = append(annotation.Steps, step)
+	}
+
+	if !isAnnotated(node) {
+		original := map[string]json.RawMessage{}
+		for _, property := range properties {
+			if value, ok := node[property]; ok {
+				original[property] = value
 			}
 		}
+
+		rawOriginal, err := json.Marshal(original)
+		if err != nil {
+			return err
+		}
+
+		object.set(annotationOriginalProperty, rawOriginal)
 	}
 
-	return output, errors
+	rawAnnotation, err := json.Marshal(annotation)
+	if err != nil {
+		return
+	}
+
+	object.set(annotationConversionProperty, rawAnnotation)
+
+	return
 }
 
-// ConvertToPreferredUnits will search through JSON and convert any magnitude/unit pair that it can find
-func (converter *JSONConverter) ConvertToPreferredUnits(input string) (output string, errors []error) {
-	var node json.RawMessage
-	err := json.Unmarshal([]byte(input), &node)
+// revertObject restores the original values of an annotated object and removes the annotation
+func revertObject(path string, object *jsonObject) (errors []error) {
+	node := object.properties()
+	if !isAnnotated(node) {
+		return
+	}
+
+	var original mapNode
+	err := json.Unmarshal(node[annotationOriginalProperty], &original)
 	if err != nil {
-		errors = append(errors, err)
+		errors = append(errors, NewQuantityError(path, "", ErrorCodeInvalidAnnotation, err))
 		return
 	}
 
-	output, errors = converter.walkJSON("", node, input)
+	for property, value := range original {
+		object.set(property, value)
+	}
+
+	object.remove(annotationOriginalProperty)
+	object.remove(annotationConversionProperty)
 
 	return
 }
 
+func streamError(err error) *QuantityError {
+	if _, ok := err.(*jsonSyntaxError); ok {
+		return NewQuantityError("", "", ErrorCodeInvalidJSON, err)
+	}
+
+	return NewQuantityError("", "", ErrorCodeWriteFailed, err)
+}
+
+// rewriteString runs rewriter on a JSON document held in a string, the output is empty if the document is not valid JSON
+func rewriteString(rewriter *jsonStreamRewriter, input string) (output string, errors []error) {
+	buffer := bytes.Buffer{}
+	errors, err := rewriter.run(strings.NewReader(input), &buffer)
+	if err != nil {
+		errors = []error{streamError(err)}
+		return
+	}
+
+	output = buffer.String()
+
+	return
+}
+
+func (converter *JSONConverter) conversionRewriter() *jsonStreamRewriter {
+	targets := map[string]preferredTarget{}
+	rewriter := &jsonStreamRewriter{
+		watched: conversionProperties,
+		rewrite: func(path string, object *jsonObject) []error {
+			return converter.convertObject(path, object, targets)
+		},
+	}
+
+	// Only annotating conversions keep the annotation properties as they are, other documents may use these names for their own quantities
+	if converter.Annotate || converter.Explain {
+		rewriter.watched = quantityProperties
+		rewriter.compound = annotationProperties
+		rewriter.opaque = annotationProperties
+	}
+
+	return rewriter
+}
+
+func revertRewriter() *jsonStreamRewriter {
+	return &jsonStreamRewriter{
+		watched:  quantityProperties,
+		compound: annotationProperties,
+		opaque:   annotationProperties,
+		rewrite:  revertObject,
+	}
+}
+
+// ConvertToPreferredUnits will search through JSON and convert any magnitude/unit pair or FHIR Quantity (value/code) that it can find, quantities that fails are left untouched and reported as *QuantityError
+func (converter *JSONConverter) ConvertToPreferredUnits(input string) (output string, errors []error) {
+	return rewriteString(converter.conversionRewriter(), input)
+}
+
+// ConvertStream works as ConvertToPreferredUnits but reads the JSON document from input and writes the converted document to output in a single pass. Only the quantity properties of an object are held in memory, so documents of any size can be converted. The output is incomplete when the document turns out not to be valid JSON, that is reported as an error with the code ErrorCodeInvalidJSON
+func (converter *JSONConverter) ConvertStream(input io.Reader, output io.Writer) (errors []error) {
+	errors, err := converter.conversionRewriter().run(input, output)
+	if err != nil {
+		errors = append(errors, streamError(err))
+	}
+
+	return
+}
+
+// RevertAnnotations restores the original quantities in JSON that was converted with JSONConverter.Annotate enabled and removes the annotations
+func (converter *JSONConverter) RevertAnnotations(input string) (output string, errors []error) {
+	return rewriteString(revertRewriter(), input)
+}
+
 // NewJSONConverterFromYAML is used to parse and verify YAML data into a Converter
 func NewJSONConverterFromYAML(raw []byte) (converter JSONConverter, err error) {
 	baseConverter, err := NewConverterFromYAML(raw)
@@ -108,7 +334,39 @@ func NewJSONConverterFromYAML(raw []byte) (converter JSONConverter, err error) {
 		return
 	}
 
-	converter = JSONConverter{baseConverter}
+	converter = NewJSONConverter(baseConverter)
+
+	return
+}
+
+// LoadJSONConverter works as LoadConverter but returns a JSONConverter
+func LoadJSONConverter(paths ...string) (converter JSONConverter, err error) {
+	baseConverter, err := LoadConverter(paths...)
+	if err != nil {
+		return
+	}
+
+	converter = NewJSONConverter(baseConverter)
+
+	return
+}
+
+// LoadJSONConverterWithDefaults works as LoadConverterWithDefaults but returns a JSONConverter
+func LoadJSONConverterWithDefaults(paths ...string) (converter JSONConverter, err error) {
+	baseConverter, err := LoadConverterWithDefaults(paths...)
+	if err != nil {
+		return
+	}
+
+	converter = NewJSONConverter(baseConverter)
 
 	return
 }
+
+// NewJSONConverter creates a JSONConverter for a Converter that has passed Converter.Test
+func NewJSONConverter(baseConverter Converter) JSONConverter {
+	// Created up front so that copies of the converter made per request share the cached paths
+	baseConverter.newPathCache()
+
+	return JSONConverter{Converter: baseConverter}
+}
@@ -1,7 +1,11 @@
-package main
+package unitconversion
 
 import (
+	"fmt"
+	"io"
 	"io/ioutil"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -50,6 +54,256 @@ func TestJSONConverterConvertToPreferredUnitsWithLargeDataSet(test *testing.T) {
 	assert.JSONEq(test, string(expectedOutput), output)
 }
 
+func TestJSONConverterConvertToPreferredUnitsWithFHIRQuantities(test *testing.T) {
+	input, err := ioutil.ReadFile("fixtures/fhirInput.json")
+	assert.NoError(test, err)
+	expectedOutput, err := ioutil.ReadFile("fixtures/fhirOutput.json")
+	assert.NoError(test, err)
+	converterConfig, err := ioutil.ReadFile("converter.yml")
+	assert.NoError(test, err)
+	converter, err := NewJSONConverterFromYAML(converterConfig)
+	assert.NoError(test, err)
+
+	output, errors := converter.ConvertToPreferredUnits(string(input))
+	assert.Empty(test, errors)
+	assert.JSONEq(test, string(expectedOutput), output)
+}
+
+func TestJSONConverterConvertToPreferredUnitsWithCompoundFHIRProperties(test *testing.T) {
+	converterConfig, err := ioutil.ReadFile("converter.yml")
+	assert.NoError(test, err)
+	converter, err := NewJSONConverterFromYAML(converterConfig)
+	assert.NoError(test, err)
+
+	input := `[{"value": 1500, "code": {"text": "mg"}}, {"value": 1500, "code": "mg", "unit": ["mg"]}, {"value": 1500, "code": "mg", "system": {}}]`
+	output, errors := converter.ConvertToPreferredUnits(input)
+	assert.Empty(test, errors)
+	assert.Equal(test, input, output)
+}
+
+func TestJSONConverterConvertToPreferredUnitsWithRootQuantity(test *testing.T) {
+	input := `{"value": 1000, "unit": "milligram", "system": "http://unitsofmeasure.org", "code": "mg"}`
+	expectedOutput := `{"value": 1, "unit": "g", "system": "http://unitsofmeasure.org", "code": "g"}`
+	converterConfig, err := ioutil.ReadFile("converter.yml")
+	assert.NoError(test, err)
+	converter, err := NewJSONConverterFromYAML(converterConfig)
+	assert.NoError(test, err)
+
+	output, errors := converter.ConvertToPreferredUnits(input)
+	assert.Empty(test, errors)
+	assert.JSONEq(test, expectedOutput, output)
+}
+
+func TestFailJSONConverterConvertToPreferredUnitsWithUnknownUnit(test *testing.T) {
+	input := `{"items": [{"magnitude": 1, "unit": "cm"}, {"magnitude": 2, "unit": "parsec"}]}`
+	expectedOutput := `{"items": [{"magnitude": 0.01, "unit": "m"}, {"magnitude": 2, "unit": "parsec"}]}`
+	converterConfig, err := ioutil.ReadFile("converter.yml")
+	assert.NoError(test, err)
+	converter, err := NewJSONConverterFromYAML(converterConfig)
+	assert.NoError(test, err)
+
+	output, errors := converter.ConvertToPreferredUnits(input)
+	assert.JSONEq(test, expectedOutput, output)
+	assert.Len(test, errors, 1)
+
+	quantityError, ok := errors[0].(*QuantityError)
+	assert.True(test, ok)
+	assert.Equal(test, "items.1", quantityError.Path)
+	assert.Equal(test, "parsec", quantityError.Unit)
+	assert.Equal(test, ErrorCodeNoPreferredUnit, quantityError.Code)
+}
+
+func TestJSONConverterConvertToPreferredUnitsWithPreservedNumbers(test *testing.T) {
+	input := `{
+		"count": {"magnitude": 12345678901234567890, "unit": "m"},
+		"exact": {"magnitude": 0.10000000000000000001, "unit": "m"},
+		"text": {"magnitude": "10.5", "unit": "cm"},
+		"fhir": {"value": "1500", "code": "mg"},
+		"small": {"magnitude": 1, "unit": "nm"}
+	}`
+	expectedOutput := `{
+		"count": {"magnitude": 12345678901234567890, "unit": "m"},
+		"exact": {"magnitude": 0.10000000000000000001, "unit": "m"},
+		"text": {"magnitude": "0.105", "unit": "m"},
+		"fhir": {"value": "1.5", "unit": "g", "code": "g"},
+		"small": {"magnitude": 1e-9, "unit": "m"}
+	}`
+	converterConfig, err := ioutil.ReadFile("converter.yml")
+	assert.NoError(test, err)
+	converter, err := NewJSONConverterFromYAML(converterConfig)
+	assert.NoError(test, err)
+	converter.AcceptStringMagnitudes = true
+
+	output, errors := converter.ConvertToPreferredUnits(input)
+	assert.Empty(test, errors)
+	assert.JSONEq(test, expectedOutput, output)
+	assert.Contains(test, output, "12345678901234567890")
+	assert.Contains(test, output, "0.10000000000000000001")
+	assert.Contains(test, output, `"0.105"`)
+}
+
+func TestJSONConverterConvertToPreferredUnitsWithConvertedPrecision(test *testing.T) {
+	converterConfig, err := ioutil.ReadFile("converter.yml")
+	assert.NoError(test, err)
+	converter, err := NewJSONConverterFromYAML(converterConfig)
+	assert.NoError(test, err)
+
+	// Converted magnitudes are float64, magnitudes with more significant digits are reported instead of being rounded
+	for magnitude, input := range map[string]string{
+		"1234567890123456789":    `{"length": {"magnitude": 1234567890123456789, "unit": "cm"}}`,
+		"0.12345678901234567891": `{"length": {"magnitude": 0.12345678901234567891, "unit": "cm"}}`,
+		"9007199254740993":       `{"length": {"value": 9007199254740993, "unit": "cm", "code": "cm"}}`,
+	} {
+		output, errors := converter.ConvertToPreferredUnits(input)
+		assert.Len(test, errors, 1, input)
+		for _, err := range errors {
+			assert.Equal(test, ErrorCodePrecisionLost, err.(*QuantityError).Code)
+			assert.Equal(test, "length", err.(*QuantityError).Path)
+		}
+		assert.Contains(test, output, magnitude)
+		assert.Contains(test, output, `"cm"`)
+	}
+
+	output, errors := converter.ConvertToPreferredUnits(`{"length": {"magnitude": 123456789012345, "unit": "cm"}}`)
+	assert.Empty(test, errors)
+	assert.JSONEq(test, `{"length": {"magnitude": 1234567890123.45, "unit": "m"}}`, output)
+
+	// Quantities that already have the preferred unit are not converted, so they keep every digit
+	output, errors = converter.ConvertToPreferredUnits(`{"length": {"magnitude": 1234567890123456789, "unit": "m"}}`)
+	assert.Empty(test, errors)
+	assert.Contains(test, output, "1234567890123456789")
+}
+
+func TestJSONConverterConvertToPreferredUnitsIgnoresStringMagnitudesByDefault(test *testing.T) {
+	input := `{"text": {"magnitude": "10.5", "unit": "cm"}}`
+	converterConfig, err := ioutil.ReadFile("converter.yml")
+	assert.NoError(test, err)
+	converter, err := NewJSONConverterFromYAML(converterConfig)
+	assert.NoError(test, err)
+
+	output, errors := converter.ConvertToPreferredUnits(input)
+	assert.Empty(test, errors)
+	assert.JSONEq(test, input, output)
+}
+
+func TestJSONConverterConvertToPreferredUnitsWithAnnotations(test *testing.T) {
+	input := `{"size": {"magnitude": 10, "unit": "cm"}, "dose": {"value": 1500, "unit": "mg", "code": "mg"}}`
+	expectedOutput := `{
+		"size": {
+			"magnitude": 0.1,
+			"unit": "m",
+			"original": {"magnitude": 10, "unit": "cm"},
+			"conversion": {"jsonPath": "size", "steps": [{"from": "cm", "to": "m", "formula": "magnitude / 100"}]}
+		},
+		"dose": {
+			"value": 1.5,
+			"unit": "g",
+			"code": "g",
+			"original": {"value": 1500, "unit": "mg", "code": "mg"},
+			"conversion": {"jsonPath": "dose", "steps": [{"from": "mg", "to": "g", "formula": "magnitude / 1000"}]}
+		}
+	}`
+	converterConfig, err := ioutil.ReadFile("converter.yml")
+	assert.NoError(test, err)
+	converter, err := NewJSONConverterFromYAML(converterConfig)
+	assert.NoError(test, err)
+	converter.Annotate = true
+
+	output, errors := converter.ConvertToPreferredUnits(input)
+	assert.Empty(test, errors)
+	assert.JSONEq(test, expectedOutput, output)
+
+	reconverted, errors := converter.ConvertToPreferredUnits(output)
+	assert.Empty(test, errors)
+	assert.JSONEq(test, expectedOutput, reconverted)
+
+	reverted, errors := converter.RevertAnnotations(output)
+	assert.Empty(test, errors)
+	assert.JSONEq(test, input, reverted)
+}
+
+func TestNewJSONConverterWithOwnPathCache(test *testing.T) {
+	baseConverter := Converter{Conversions: []Conversion{Conversion{From: "cm", To: "m", Formula: "magnitude / 100"}}}
+	first := NewJSONConverter(baseConverter)
+	second := NewJSONConverter(baseConverter)
+	copied := first
+
+	assert.NotSame(test, first.cacheLock(), second.cacheLock())
+	assert.Same(test, first.cacheLock(), copied.cacheLock())
+
+	_, err := first.Convert(Quantity{Magnitude: 100, Unit: "cm"}, "m")
+	assert.NoError(test, err)
+	assert.Len(test, first.PathCache, 1)
+	assert.Len(test, copied.PathCache, 1)
+	assert.Empty(test, second.PathCache)
+}
+
+func TestJSONConverterConvertToPreferredUnitsWithExplain(test *testing.T) {
+	input := `{"size": {"magnitude": 10, "unit": "cm"}}`
+	expectedOutput := `{
+		"size": {
+			"magnitude": 0.1,
+			"unit": "m",
+			"original": {"magnitude": 10, "unit": "cm"},
+			"conversion": {"jsonPath": "size", "steps": [{"from": "cm", "to": "m", "formula": "magnitude / 100", "magnitude": 0.1}]}
+		}
+	}`
+	converterConfig, err := ioutil.ReadFile("converter.yml")
+	assert.NoError(test, err)
+	converter, err := NewJSONConverterFromYAML(converterConfig)
+	assert.NoError(test, err)
+	converter.Explain = true
+
+	output, errors := converter.ConvertToPreferredUnits(input)
+	assert.Empty(test, errors)
+	assert.JSONEq(test, expectedOutput, output)
+
+	reverted, errors := converter.RevertAnnotations(output)
+	assert.Empty(test, errors)
+	assert.JSONEq(test, input, reverted)
+}
+
+func TestJSONConverterConvertToPreferredUnitsWithAnnotationPropertyNames(test *testing.T) {
+	input := `{"original": {"magnitude": 10, "unit": "cm"}, "conversion": {"rate": {"value": 1500, "unit": "mg", "code": "mg"}}}`
+	converterConfig, err := ioutil.ReadFile("converter.yml")
+	assert.NoError(test, err)
+	converter, err := NewJSONConverterFromYAML(converterConfig)
+	assert.NoError(test, err)
+
+	output, errors := converter.ConvertToPreferredUnits(input)
+	assert.Empty(test, errors)
+	assert.JSONEq(test, `{"original": {"magnitude": 0.1, "unit": "m"}, "conversion": {"rate": {"value": 1.5, "unit": "g", "code": "g"}}}`, output)
+
+	rewriter := converter.conversionRewriter()
+	assert.Empty(test, rewriter.compound)
+	assert.Empty(test, rewriter.opaque)
+}
+
+func TestJSONConverterRevertAnnotationsWithLargeDataSet(test *testing.T) {
+	input, err := ioutil.ReadFile("fixtures/inputLarge.json")
+	assert.NoError(test, err)
+	converterConfig, err := ioutil.ReadFile("converter.yml")
+	assert.NoError(test, err)
+	converter, err := NewJSONConverterFromYAML(converterConfig)
+	assert.NoError(test, err)
+	converter.Annotate = true
+
+	output, errors := converter.ConvertToPreferredUnits(string(input))
+	assert.Empty(test, errors)
+
+	reverted, errors := converter.RevertAnnotations(output)
+	assert.Empty(test, errors)
+	assert.JSONEq(test, string(input), reverted)
+}
+
+func TestFailJSONConverterRevertAnnotationsWithBadSyntax(test *testing.T) {
+	converter := JSONConverter{}
+
+	output, errors := converter.RevertAnnotations(`{ "original": `)
+	assert.NotEmpty(test, errors)
+	assert.Equal(test, "", output)
+}
+
 func TestNewJSONConverterFromYAML(test *testing.T) {
 	badConfig := "broken yaml¤-:4"
 	expectedOutput := JSONConverter{}
@@ -59,6 +313,100 @@ func TestNewJSONConverterFromYAML(test *testing.T) {
 	assert.Equal(test, expectedOutput, converter)
 }
 
+// quantityDocumentReader generates a JSON document with a watched property followed by a large array of quantities without keeping the document in memory
+type quantityDocumentReader struct {
+	prefix  string
+	item    string
+	suffix  string
+	count   int
+	pending []byte
+}
+
+func (reader *quantityDocumentReader) Read(data []byte) (count int, err error) {
+	for len(reader.pending) == 0 {
+		switch {
+		case reader.prefix != "":
+			reader.pending, reader.prefix = []byte(reader.prefix), ""
+		case reader.count > 0:
+			reader.pending = []byte(reader.item)
+			reader.count--
+			if reader.count == 0 {
+				reader.pending = reader.pending[:len(reader.pending)-1]
+			}
+		case reader.suffix != "":
+			reader.pending, reader.suffix = []byte(reader.suffix), ""
+		default:
+			return 0, io.EOF
+		}
+	}
+
+	count = copy(data, reader.pending)
+	reader.pending = reader.pending[count:]
+
+	return
+}
+
+// peakHeapReader records the largest heap size seen while a document is read
+type peakHeapReader struct {
+	io.Reader
+	read     int
+	peakHeap uint64
+}
+
+func (reader *peakHeapReader) Read(data []byte) (count int, err error) {
+	count, err = reader.Reader.Read(data)
+	reader.read += count
+	if reader.read >= 1<<20 {
+		reader.read = 0
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		if stats.HeapAlloc > reader.peakHeap {
+			reader.peakHeap = stats.HeapAlloc
+		}
+	}
+
+	return
+}
+
+func TestJSONConverterConvertStreamWithBoundedMemory(test *testing.T) {
+	if testing.Short() {
+		test.Skip("converts a 32 MB document")
+	}
+
+	converterConfig, err := ioutil.ReadFile("converter.yml")
+	assert.NoError(test, err)
+	converter, err := NewJSONConverterFromYAML(converterConfig)
+	assert.NoError(test, err)
+
+	item := `{"magnitude": 1, "unit": "cm", "note": "the quantities are converted as they stream through"},`
+	document := &quantityDocumentReader{
+		prefix: `{"code": "x", "entries": [`,
+		item:   item,
+		suffix: `]}`,
+		count:  (32 << 20) / len(item),
+	}
+
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	reader := &peakHeapReader{Reader: document, peakHeap: stats.HeapAlloc}
+
+	counter := &byteCounter{}
+	errors := converter.ConvertStream(reader, counter)
+	assert.Empty(test, errors)
+	assert.Greater(test, counter.count, 32<<20)
+	assert.Less(test, reader.peakHeap-stats.HeapAlloc, uint64(16<<20))
+}
+
+type byteCounter struct {
+	count int
+}
+
+func (counter *byteCounter) Write(data []byte) (int, error) {
+	counter.count += len(data)
+	return len(data), nil
+}
+
 func BenchmarkNewJSONConverterFromYAMLLargeDataSet(benchmark *testing.B) {
 	converterConfig, err := ioutil.ReadFile("converter.yml")
 	if err != nil {
@@ -94,6 +442,85 @@ func BenchmarkJSONConverterConvertToPreferredUnitsLargeDataSet(benchmark *testin
 	}
 }
 
+func BenchmarkJSONConverterConvertToPreferredUnitsScaledDataSet(benchmark *testing.B) {
+	input, err := ioutil.ReadFile("fixtures/inputLarge.json")
+	if err != nil {
+		panic(err)
+	}
+
+	converterConfig, err := ioutil.ReadFile("converter.yml")
+	if err != nil {
+		panic(err)
+	}
+
+	converter, err := NewJSONConverterFromYAML(converterConfig)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, copies := range []int{1, 4, 16} {
+		scaledInput := "[" + strings.TrimSpace(strings.Repeat(string(input)+",", copies)) + "]"
+		scaledInput = strings.Replace(scaledInput, ",]", "]", 1)
+
+		benchmark.Run(fmt.Sprintf("%dx", copies), func(benchmark *testing.B) {
+			benchmark.SetBytes(int64(len(scaledInput)))
+			for index := 0; index < benchmark.N; index++ {
+				converter.ConvertToPreferredUnits(scaledInput)
+			}
+		})
+	}
+}
+
+// BenchmarkJSONConverterConvertToPreferredUnitsParts separates the cost of reading and writing the document from the cost of the conversions, and shows what the per document cache of preferred units saves
+func BenchmarkJSONConverterConvertToPreferredUnitsParts(benchmark *testing.B) {
+	input, err := ioutil.ReadFile("fixtures/inputLarge.json")
+	if err != nil {
+		panic(err)
+	}
+
+	converterConfig, err := ioutil.ReadFile("converter.yml")
+	if err != nil {
+		panic(err)
+	}
+
+	converter, err := NewJSONConverterFromYAML(converterConfig)
+	if err != nil {
+		panic(err)
+	}
+
+	rewriters := map[string]func() *jsonStreamRewriter{
+		"Streaming": func() *jsonStreamRewriter {
+			return &jsonStreamRewriter{
+				watched:  quantityProperties,
+				compound: annotationProperties,
+				opaque:   annotationProperties,
+				rewrite:  func(path string, object *jsonObject) []error { return nil },
+			}
+		},
+		"WithoutTargetCache": func() *jsonStreamRewriter {
+			return &jsonStreamRewriter{
+				watched:  quantityProperties,
+				compound: annotationProperties,
+				opaque:   annotationProperties,
+				rewrite: func(path string, object *jsonObject) []error {
+					return converter.convertObject(path, object, map[string]preferredTarget{})
+				},
+			}
+		},
+		"WithTargetCache": converter.conversionRewriter,
+	}
+
+	for _, name := range []string{"Streaming", "WithoutTargetCache", "WithTargetCache"} {
+		newRewriter := rewriters[name]
+		benchmark.Run(name, func(benchmark *testing.B) {
+			benchmark.SetBytes(int64(len(input)))
+			for index := 0; index < benchmark.N; index++ {
+				rewriteString(newRewriter(), string(input))
+			}
+		})
+	}
+}
+
 func BenchmarkJSONConverterConvertToPreferredUnit(benchmark *testing.B) {
 	converter := Converter{
 		PreferredUnits: []string{"in"},
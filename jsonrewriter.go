@@ -0,0 +1,748 @@
+package unitconversion
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// maxJSONDepth limits how deeply nested objects and arrays can be, the same limit as in encoding/json
+const maxJSONDepth = 10000
+
+// jsonSink is where the rewriter writes, either the output writer or the buffer of an object that is held for rewrite
+type jsonSink interface {
+	Write(data []byte) (int, error)
+	WriteByte(data byte) error
+}
+
+// jsonHeldObjectLimit is how many bytes of properties that are not rewritten an object may hold before they are written out, so that a watched property next to a large array does not hold the whole array in memory
+const jsonHeldObjectLimit = 64 << 10
+
+// jsonMember is one property of a jsonObject, the offsets point into jsonObject.buffer
+type jsonMember struct {
+	key         string
+	start       int
+	keyStart    int
+	valueStart  int
+	valueEnd    int
+	replacement []byte
+	deleted     bool
+	// held members are available to rewrite, the other members are only held until the object grows beyond jsonHeldObjectLimit
+	held bool
+	// placeholder stands in for the value of a watched member that is not held, "{}" or "[]" so that rewrite can see that the property is there
+	placeholder []byte
+	// spilled members have already been written out and have no bytes in jsonObject.buffer
+	spilled bool
+}
+
+type jsonAppendedMember struct {
+	key   string
+	value []byte
+}
+
+// jsonObject is an object held in memory by jsonStreamRewriter from its first watched property until it ends. It is the sink for the values of its members, so that it can write out the members that are not held once it grows beyond jsonHeldObjectLimit
+type jsonObject struct {
+	buffer   bytes.Buffer
+	members  []jsonMember
+	appended []jsonAppendedMember
+	tail     []byte
+	sink     jsonSink
+	// emitted is true when a property has already been written to sink
+	emitted bool
+	// current is the member whose value is being read
+	current *jsonMember
+	// streaming is true when current has been spilled and the rest of its value goes straight to sink
+	streaming bool
+}
+
+func (object *jsonObject) Write(data []byte) (count int, err error) {
+	if object.streaming {
+		return object.sink.Write(data)
+	}
+
+	count, err = object.buffer.Write(data)
+	object.limit()
+
+	return
+}
+
+func (object *jsonObject) WriteByte(data byte) (err error) {
+	if object.streaming {
+		return object.sink.WriteByte(data)
+	}
+
+	err = object.buffer.WriteByte(data)
+	object.limit()
+
+	return
+}
+
+// limit spills the object when the member that is being read is not held and the object has grown beyond jsonHeldObjectLimit
+func (object *jsonObject) limit() {
+	if object.current != nil && !object.current.held && object.buffer.Len() > jsonHeldObjectLimit {
+		object.spill()
+	}
+}
+
+// writeMember writes a member to sink with the comma that it needs, separator is the whitespace and comma that came before the member in the input
+func (object *jsonObject) writeMember(separator []byte, key []byte, value []byte) {
+	if comma := bytes.IndexByte(separator, ','); comma >= 0 {
+		object.sink.Write(separator[:comma])
+		separator = separator[comma+1:]
+	}
+
+	if object.emitted {
+		object.sink.WriteByte(',')
+	}
+
+	object.sink.Write(separator)
+	object.sink.Write(key)
+	object.sink.Write(value)
+	object.emitted = true
+}
+
+// spill writes the members that are not held to sink, followed by what has been read of the current member, only the held members are kept in memory. The held members are written after the spilled ones when the object ends, so their order in the output changes
+func (object *jsonObject) spill() {
+	raw := object.buffer.Bytes()
+	kept := bytes.Buffer{}
+	members := make([]jsonMember, 0, len(object.members))
+
+	for _, member := range object.members {
+		if member.held || member.spilled {
+			if member.held {
+				offset := kept.Len() - member.start
+				kept.Write(raw[member.start:member.valueEnd])
+				member.start += offset
+				member.keyStart += offset
+				member.valueStart += offset
+				member.valueEnd += offset
+			}
+			members = append(members, member)
+			continue
+		}
+
+		if !member.deleted {
+			object.writeMember(raw[member.start:member.keyStart], raw[member.keyStart:member.valueStart], raw[member.valueStart:member.valueEnd])
+		}
+
+		if member.placeholder != nil {
+			member.spilled = true
+			members = append(members, member)
+		}
+	}
+
+	current := object.current
+	object.writeMember(raw[current.start:current.keyStart], raw[current.keyStart:current.valueStart], raw[current.valueStart:])
+	current.spilled = true
+
+	object.buffer = kept
+	object.members = members
+	object.streaming = true
+}
+
+// add finishes the current member, a spilled member is only kept when it has a placeholder
+func (object *jsonObject) add() {
+	member := object.current
+	object.current = nil
+	if object.streaming {
+		object.streaming = false
+		if member.placeholder == nil {
+			return
+		}
+	} else {
+		member.valueEnd = object.buffer.Len()
+	}
+
+	object.members = append(object.members, *member)
+}
+
+func (object *jsonObject) lookup(key string) int {
+	for index := len(object.members) - 1; index >= 0; index-- {
+		if object.members[index].key == key && object.members[index].held && !object.members[index].deleted {
+			return index
+		}
+	}
+
+	return -1
+}
+
+func (object *jsonObject) value(index int) json.RawMessage {
+	member := object.members[index]
+	if member.replacement != nil {
+		return member.replacement
+	}
+
+	return object.buffer.Bytes()[member.valueStart:member.valueEnd]
+}
+
+// properties returns the watched properties and their current raw values, watched properties whose values are not held are given as their placeholder
+func (object *jsonObject) properties() (node mapNode) {
+	node = mapNode{}
+	for index, member := range object.members {
+		if member.deleted {
+			continue
+		}
+
+		if member.held {
+			node[member.key] = object.value(index)
+		} else if member.placeholder != nil {
+			node[member.key] = member.placeholder
+		}
+	}
+
+	for _, member := range object.appended {
+		node[member.key] = member.value
+	}
+
+	return
+}
+
+// set replaces the raw value of a held property or adds the property at the end of the object if it is not held
+func (object *jsonObject) set(key string, raw []byte) {
+	if index := object.lookup(key); index >= 0 {
+		object.members[index].replacement = raw
+		return
+	}
+
+	for index := range object.appended {
+		if object.appended[index].key == key {
+			object.appended[index].value = raw
+			return
+		}
+	}
+
+	object.appended = append(object.appended, jsonAppendedMember{key: key, value: raw})
+}
+
+// remove deletes all held properties with the name key
+func (object *jsonObject) remove(key string) {
+	for index := range object.members {
+		if object.members[index].key == key && object.members[index].held {
+			object.members[index].deleted = true
+		}
+	}
+
+	appended := object.appended[:0]
+	for _, member := range object.appended {
+		if member.key != key {
+			appended = append(appended, member)
+		}
+	}
+	object.appended = appended
+}
+
+// render writes the rest of the object to sink with all changes applied, whitespace of the input is kept
+func (object *jsonObject) render() {
+	raw := object.buffer.Bytes()
+
+	for index, member := range object.members {
+		if member.deleted || member.spilled {
+			continue
+		}
+
+		object.writeMember(raw[member.start:member.keyStart], raw[member.keyStart:member.valueStart], object.value(index))
+	}
+
+	for _, member := range object.appended {
+		if object.emitted {
+			object.sink.WriteByte(',')
+		}
+
+		object.sink.Write([]byte(quoteJSON(member.key)))
+		object.sink.WriteByte(':')
+		object.sink.Write(member.value)
+		object.emitted = true
+	}
+
+	object.sink.Write(object.tail)
+}
+
+// jsonStreamRewriter copies a JSON document from a reader to a writer in a single pass. Objects are written as they are read until a watched property shows up, from there the object is held in memory until it ends so that rewrite can change it. Only the scalar values of watched properties, and the values of the compound properties, are always held. The other properties are written out when the object grows beyond jsonHeldObjectLimit, so memory use is bounded by the size of the watched values rather than by the size of the document.
+type jsonStreamRewriter struct {
+	// watched are the properties that makes an object interesting for rewrite, the scalar values of watched properties are available to rewrite
+	watched map[string]bool
+	// compound are the watched properties whose object and array values are also available to rewrite
+	compound map[string]bool
+	// opaque are the properties whose values are copied without rewriting anything inside them
+	opaque  map[string]bool
+	rewrite func(path string, object *jsonObject) []error
+
+	reader *bufio.Reader
+	offset int
+	errors []error
+}
+
+func (rewriter *jsonStreamRewriter) run(input io.Reader, output io.Writer) (errors []error, err error) {
+	rewriter.reader = bufio.NewReader(input)
+	rewriter.offset = 0
+	rewriter.errors = nil
+	writer := bufio.NewWriter(output)
+
+	err = rewriter.whitespace(writer)
+	if err == nil {
+		err = rewriter.value("", writer, 0, true)
+	}
+
+	if err == nil {
+		err = rewriter.whitespace(writer)
+	}
+
+	if err == nil {
+		if _, readErr := rewriter.next(); readErr != io.ErrUnexpectedEOF {
+			err = rewriter.syntaxError("unexpected data after top-level value")
+		}
+	}
+
+	if err != nil {
+		return rewriter.errors, err
+	}
+
+	return rewriter.errors, writer.Flush()
+}
+
+// jsonSyntaxError is returned by jsonStreamRewriter.run when the input is not valid JSON, any other error comes from reading or writing
+type jsonSyntaxError struct {
+	offset  int
+	message string
+}
+
+func (syntaxError *jsonSyntaxError) Error() string {
+	return fmt.Sprintf("Invalid JSON at offset %d: %s", syntaxError.offset, syntaxError.message)
+}
+
+func (rewriter *jsonStreamRewriter) syntaxError(message string) error {
+	return &jsonSyntaxError{offset: rewriter.offset, message: message}
+}
+
+// next reads one byte, the end of the input is reported as io.ErrUnexpectedEOF since a complete document never needs more bytes than it has
+func (rewriter *jsonStreamRewriter) next() (data byte, err error) {
+	data, err = rewriter.reader.ReadByte()
+	if err == io.EOF {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	if err == nil {
+		rewriter.offset++
+	}
+
+	return
+}
+
+func (rewriter *jsonStreamRewriter) unread() {
+	rewriter.reader.UnreadByte()
+	rewriter.offset--
+}
+
+func (rewriter *jsonStreamRewriter) expected(what string, err error) error {
+	if err == io.ErrUnexpectedEOF {
+		return rewriter.syntaxError("unexpected end of input, expected " + what)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return rewriter.syntaxError("expected " + what)
+}
+
+func (rewriter *jsonStreamRewriter) whitespace(sink jsonSink) (err error) {
+	for {
+		data, readErr := rewriter.next()
+		if readErr == io.ErrUnexpectedEOF {
+			return nil
+		}
+
+		if readErr != nil {
+			return readErr
+		}
+
+		if data != ' ' && data != '\t' && data != '\n' && data != '\r' {
+			rewriter.unread()
+			return nil
+		}
+
+		sink.WriteByte(data)
+	}
+}
+
+func (rewriter *jsonStreamRewriter) value(path string, sink jsonSink, depth int, visit bool) (err error) {
+	data, err := rewriter.next()
+	if err != nil {
+		return rewriter.expected("value", err)
+	}
+
+	switch {
+	case data == '{':
+		return rewriter.object(path, sink, depth+1, visit)
+	case data == '[':
+		return rewriter.array(path, sink, depth+1, visit)
+	case data == '"':
+		_, err = rewriter.copyString(sink)
+		return
+	case data == '-' || (data >= '0' && data <= '9'):
+		return rewriter.copyNumber(data, sink)
+	case data == 't':
+		return rewriter.copyLiteral("true", sink)
+	case data == 'f':
+		return rewriter.copyLiteral("false", sink)
+	case data == 'n':
+		return rewriter.copyLiteral("null", sink)
+	}
+
+	return rewriter.syntaxError(fmt.Sprintf("unexpected character %q", data))
+}
+
+func (rewriter *jsonStreamRewriter) object(path string, sink jsonSink, depth int, visit bool) (err error) {
+	if depth > maxJSONDepth {
+		return rewriter.syntaxError("exceeded max depth")
+	}
+
+	sink.WriteByte('{')
+
+	var object *jsonObject
+	pending := bytes.Buffer{}
+	emitted := false
+
+	err = rewriter.whitespace(&pending)
+	if err != nil {
+		return
+	}
+
+	data, err := rewriter.next()
+	if err != nil {
+		return rewriter.expected("property name or '}'", err)
+	}
+
+	if data != '}' {
+		for {
+			if data != '"' {
+				return rewriter.syntaxError("expected property name")
+			}
+
+			keyStart := pending.Len()
+			var key string
+			key, err = rewriter.copyString(&pending)
+			if err != nil {
+				return
+			}
+
+			err = rewriter.whitespace(&pending)
+			if err != nil {
+				return
+			}
+
+			data, err = rewriter.next()
+			if err != nil || data != ':' {
+				return rewriter.expected("':' after property name", err)
+			}
+			pending.WriteByte(':')
+
+			err = rewriter.whitespace(&pending)
+			if err != nil {
+				return
+			}
+
+			if object == nil && visit && rewriter.watched[key] {
+				object = &jsonObject{sink: sink, emitted: emitted}
+			}
+
+			memberPath := joinJSONPath(path, key)
+			memberVisit := visit && !rewriter.opaque[key]
+			if object != nil {
+				member := jsonMember{key: key, start: object.buffer.Len()}
+				member.keyStart = member.start + keyStart
+				if rewriter.watched[key] {
+					first, _ := rewriter.reader.Peek(1)
+					switch {
+					case len(first) == 0 || (first[0] != '{' && first[0] != '['):
+						member.held = true
+					case rewriter.compound[key]:
+						member.held = true
+					case first[0] == '{':
+						member.placeholder = []byte("{}")
+					default:
+						member.placeholder = []byte("[]")
+					}
+				}
+
+				object.buffer.Write(pending.Bytes())
+				member.valueStart = object.buffer.Len()
+				object.current = &member
+				err = rewriter.value(memberPath, object, depth, memberVisit)
+				object.add()
+			} else {
+				sink.Write(pending.Bytes())
+				err = rewriter.value(memberPath, sink, depth, memberVisit)
+				emitted = true
+			}
+
+			if err != nil {
+				return
+			}
+
+			pending.Reset()
+			err = rewriter.whitespace(&pending)
+			if err != nil {
+				return
+			}
+
+			data, err = rewriter.next()
+			if err != nil {
+				return rewriter.expected("',' or '}'", err)
+			}
+
+			if data == '}' {
+				break
+			}
+
+			if data != ',' {
+				return rewriter.syntaxError("expected ',' or '}' after property value")
+			}
+			pending.WriteByte(',')
+
+			err = rewriter.whitespace(&pending)
+			if err != nil {
+				return
+			}
+
+			data, err = rewriter.next()
+			if err != nil {
+				return rewriter.expected("property name", err)
+			}
+		}
+	}
+
+	if object != nil {
+		object.tail = pending.Bytes()
+		rewriter.errors = append(rewriter.errors, rewriter.rewrite(path, object)...)
+		object.render()
+	} else {
+		sink.Write(pending.Bytes())
+	}
+
+	return sink.WriteByte('}')
+}
+
+func (rewriter *jsonStreamRewriter) array(path string, sink jsonSink, depth int, visit bool) (err error) {
+	if depth > maxJSONDepth {
+		return rewriter.syntaxError("exceeded max depth")
+	}
+
+	sink.WriteByte('[')
+
+	err = rewriter.whitespace(sink)
+	if err != nil {
+		return
+	}
+
+	data, err := rewriter.next()
+	if err != nil {
+		return rewriter.expected("value or ']'", err)
+	}
+
+	if data == ']' {
+		return sink.WriteByte(']')
+	}
+	rewriter.unread()
+
+	for index := 0; ; index++ {
+		err = rewriter.value(joinJSONPath(path, strconv.Itoa(index)), sink, depth, visit)
+		if err != nil {
+			return
+		}
+
+		err = rewriter.whitespace(sink)
+		if err != nil {
+			return
+		}
+
+		data, err = rewriter.next()
+		if err != nil {
+			return rewriter.expected("',' or ']'", err)
+		}
+
+		if data == ']' {
+			return sink.WriteByte(']')
+		}
+
+		if data != ',' {
+			return rewriter.syntaxError("expected ',' or ']' after array element")
+		}
+		sink.WriteByte(',')
+
+		err = rewriter.whitespace(sink)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// copyString copies a string whose opening quote has already been read and returns its decoded value
+func (rewriter *jsonStreamRewriter) copyString(sink jsonSink) (value string, err error) {
+	raw := []byte{'"'}
+	escaped := false
+
+	for {
+		data, readErr := rewriter.next()
+		if readErr != nil {
+			return "", rewriter.expected("end of string", readErr)
+		}
+
+		raw = append(raw, data)
+
+		if data < 0x20 {
+			return "", rewriter.syntaxError("control character in string")
+		}
+
+		if data == '"' {
+			break
+		}
+
+		if data == '\\' {
+			escaped = true
+			escape, readErr := rewriter.next()
+			if readErr != nil {
+				return "", rewriter.expected("escape sequence", readErr)
+			}
+			raw = append(raw, escape)
+
+			switch escape {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+			case 'u':
+				for count := 0; count < 4; count++ {
+					hex, readErr := rewriter.next()
+					if readErr != nil {
+						return "", rewriter.expected("hexadecimal digit", readErr)
+					}
+
+					if !(hex >= '0' && hex <= '9' || hex >= 'a' && hex <= 'f' || hex >= 'A' && hex <= 'F') {
+						return "", rewriter.syntaxError("invalid unicode escape in string")
+					}
+					raw = append(raw, hex)
+				}
+			default:
+				return "", rewriter.syntaxError("invalid escape in string")
+			}
+		}
+	}
+
+	sink.Write(raw)
+
+	if !escaped {
+		return string(raw[1 : len(raw)-1]), nil
+	}
+
+	err = json.Unmarshal(raw, &value)
+
+	return
+}
+
+// copyNumber copies a number whose first character has already been read
+func (rewriter *jsonStreamRewriter) copyNumber(first byte, sink jsonSink) (err error) {
+	raw := []byte{first}
+
+	digits := func() (count int, err error) {
+		for {
+			data, readErr := rewriter.next()
+			if readErr == io.ErrUnexpectedEOF {
+				return count, nil
+			}
+
+			if readErr != nil {
+				return count, readErr
+			}
+
+			if data < '0' || data > '9' {
+				rewriter.unread()
+				return count, nil
+			}
+
+			raw = append(raw, data)
+			count++
+		}
+	}
+
+	optional := func(accepted string) (found bool, err error) {
+		data, readErr := rewriter.next()
+		if readErr == io.ErrUnexpectedEOF {
+			return false, nil
+		}
+
+		if readErr != nil {
+			return false, readErr
+		}
+
+		if bytes.IndexByte([]byte(accepted), data) < 0 {
+			rewriter.unread()
+			return false, nil
+		}
+
+		raw = append(raw, data)
+		return true, nil
+	}
+
+	if first == '-' {
+		data, readErr := rewriter.next()
+		if readErr != nil || data < '0' || data > '9' {
+			return rewriter.expected("digit after '-'", readErr)
+		}
+		raw = append(raw, data)
+	}
+
+	if raw[len(raw)-1] != '0' {
+		if _, err = digits(); err != nil {
+			return
+		}
+	}
+
+	if found, err := optional("."); err != nil {
+		return err
+	} else if found {
+		count, err := digits()
+		if err != nil {
+			return err
+		}
+
+		if count == 0 {
+			return rewriter.syntaxError("expected digit after decimal point")
+		}
+	}
+
+	if found, err := optional("eE"); err != nil {
+		return err
+	} else if found {
+		if _, err := optional("+-"); err != nil {
+			return err
+		}
+
+		count, err := digits()
+		if err != nil {
+			return err
+		}
+
+		if count == 0 {
+			return rewriter.syntaxError("expected digit in exponent")
+		}
+	}
+
+	_, err = sink.Write(raw)
+
+	return
+}
+
+// copyLiteral copies true, false or null whose first character has already been read
+func (rewriter *jsonStreamRewriter) copyLiteral(literal string, sink jsonSink) (err error) {
+	for index := 1; index < len(literal); index++ {
+		data, readErr := rewriter.next()
+		if readErr != nil || data != literal[index] {
+			return rewriter.expected(literal, readErr)
+		}
+	}
+
+	_, err = sink.Write([]byte(literal))
+
+	return
+}
@@ -0,0 +1,187 @@
+package unitconversion
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func rewriteWith(rewrite func(path string, object *jsonObject) []error, input string) (output string, err error) {
+	rewriter := jsonStreamRewriter{
+		watched: map[string]bool{"a": true, "b": true},
+		opaque:  map[string]bool{"skip": true},
+		rewrite: rewrite,
+	}
+
+	buffer := bytes.Buffer{}
+	_, err = rewriter.run(strings.NewReader(input), &buffer)
+	output = buffer.String()
+
+	return
+}
+
+func TestJSONStreamRewriterKeepsDocumentsUnchanged(test *testing.T) {
+	inputs := []string{
+		`{}`,
+		`[]`,
+		` { "a" : 1 , "b" : [ 1 , 2 ] , "c" : { } } `,
+		`{"c": "µg/l \"quoted\" \\ \n", "a": -0.5e+10, "list": [true, false, null, 0, -0, 1E3]}`,
+		`"text"`,
+		`12`,
+		"[\n\t{\"a\": {\"b\": {}}},\r\n\t[]\n]",
+	}
+
+	for _, input := range inputs {
+		output, err := rewriteWith(func(path string, object *jsonObject) []error { return nil }, input)
+		assert.NoError(test, err, input)
+		assert.Equal(test, input, output)
+	}
+
+	fixtures := []string{"fixtures/input.json", "fixtures/inputLarge.json", "fixtures/fhirInput.json"}
+	for _, fixture := range fixtures {
+		input, err := ioutil.ReadFile(fixture)
+		assert.NoError(test, err)
+
+		output, err := rewriteWith(func(path string, object *jsonObject) []error { return nil }, string(input))
+		assert.NoError(test, err, fixture)
+		assert.Equal(test, string(input), output)
+	}
+}
+
+func TestFailJSONStreamRewriterWithInvalidJSON(test *testing.T) {
+	inputs := []string{
+		``,
+		` `,
+		`{`,
+		`{"a"}`,
+		`{"a":}`,
+		`{"a":1,}`,
+		`{,"a":1}`,
+		`{"a":1 "b":2}`,
+		`[1,]`,
+		`[1 2]`,
+		`01`,
+		`1.`,
+		`1e`,
+		`-`,
+		`.5`,
+		`tru`,
+		`nul`,
+		`"unterminated`,
+		"\"control \x01\"",
+		`"\x"`,
+		`"\u12g4"`,
+		`{} {}`,
+		`{"a": 1}]`,
+		`{1: 2}`,
+		strings.Repeat("[", maxJSONDepth+1) + strings.Repeat("]", maxJSONDepth+1),
+	}
+
+	for _, input := range inputs {
+		assert.False(test, json.Valid([]byte(input)), input)
+
+		_, err := rewriteWith(func(path string, object *jsonObject) []error { return nil }, input)
+		assert.Error(test, err, input)
+		_, isSyntaxError := err.(*jsonSyntaxError)
+		assert.True(test, isSyntaxError, input)
+	}
+}
+
+func TestJSONStreamRewriterRewritesWatchedObjects(test *testing.T) {
+	input := `{"before": {"x": 1}, "a": 1, "middle": [{"a": 2, "skip": {"a": 3}}], "b": "two", "after": true}`
+	expectedOutput := `{"before": {"x": 1}, "a": 10, "middle": [{"a": 20, "skip": {"a": 3},"added":"middle.0"}], "b": "two", "after": true,"added":""}`
+	paths := []string{}
+
+	output, err := rewriteWith(func(path string, object *jsonObject) []error {
+		paths = append(paths, path)
+
+		var value int
+		json.Unmarshal(object.properties()["a"], &value)
+		object.set("a", []byte(strconv.Itoa(value*10)))
+		object.set("added", []byte(quoteJSON(path)))
+
+		return nil
+	}, input)
+
+	assert.NoError(test, err)
+	assert.Equal(test, expectedOutput, output)
+	assert.Equal(test, []string{"middle.0", ""}, paths)
+}
+
+func TestJSONStreamRewriterRemovesProperties(test *testing.T) {
+	cases := map[string]string{
+		`{"a": 1, "b": 2}`:              `{ "b": 2}`,
+		`{"a": 1}`:                      `{}`,
+		`{"x": 0, "a": 1, "b": 2}`:      `{"x": 0, "b": 2}`,
+		`{"x": 0, "b": 2, "a": 1}`:      `{"x": 0, "b": 2}`,
+		`{"a": 1, "a": 1, "b": 2}`:      `{ "b": 2}`,
+		"{\n  \"a\": 1,\n  \"b\": 2\n}": "{\n  \"b\": 2\n}",
+	}
+
+	for input, expectedOutput := range cases {
+		output, err := rewriteWith(func(path string, object *jsonObject) []error {
+			object.remove("a")
+			return nil
+		}, input)
+
+		assert.NoError(test, err, input)
+		assert.Equal(test, expectedOutput, output, input)
+		assert.True(test, json.Valid([]byte(output)), output)
+	}
+}
+
+func TestJSONStreamRewriterSpillsLargeObjects(test *testing.T) {
+	large := "[" + strings.Repeat(`"0123456789",`, jsonHeldObjectLimit/10) + `"end"]`
+	input := `{"a": 1, "large": ` + large + `, "b": 2}`
+	expectedOutput := `{ "large": ` + large + `,"a": 10, "b": 2}`
+
+	output, err := rewriteWith(func(path string, object *jsonObject) []error {
+		properties := object.properties()
+		assert.Equal(test, json.RawMessage(`1`), properties["a"])
+		assert.NotContains(test, properties, "large")
+
+		object.set("a", []byte("10"))
+		return nil
+	}, input)
+
+	assert.NoError(test, err)
+	assert.Equal(test, expectedOutput, output)
+	assert.True(test, json.Valid([]byte(output)))
+}
+
+func TestJSONStreamRewriterWithWatchedCompoundValues(test *testing.T) {
+	large := `{"list": [` + strings.Repeat(`"0123456789",`, jsonHeldObjectLimit/10) + `"end"]}`
+	for _, value := range []string{`{"x": 1}`, `[1, 2]`, large} {
+		input := `{"x": 0, "a": ` + value + `, "b": 1}`
+		output, err := rewriteWith(func(path string, object *jsonObject) []error {
+			assert.Equal(test, json.RawMessage(value[:1]+value[len(value)-1:]), object.properties()["a"])
+			object.set("b", []byte("2"))
+			return nil
+		}, input)
+
+		assert.NoError(test, err)
+		assert.Equal(test, `{"x": 0, "a": `+value+`, "b": 2}`, output)
+	}
+}
+
+func TestJSONStreamRewriterCollectsErrors(test *testing.T) {
+	rewriter := jsonStreamRewriter{
+		watched: map[string]bool{"a": true},
+		rewrite: func(path string, object *jsonObject) []error {
+			return []error{NewQuantityError(path, "", ErrorCodeConversionFailed, assert.AnError)}
+		},
+	}
+
+	buffer := bytes.Buffer{}
+	errors, err := rewriter.run(strings.NewReader(`[{"a": 1}, {"b": 2}, {"a": 3}]`), &buffer)
+
+	assert.NoError(test, err)
+	assert.Len(test, errors, 2)
+	assert.Equal(test, "0", errors[0].(*QuantityError).Path)
+	assert.Equal(test, "2", errors[1].(*QuantityError).Path)
+}
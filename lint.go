@@ -0,0 +1,288 @@
+package unitconversion
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Checks reported in LintIssue.Check
+const (
+	LintCheckRoundTrip                = "round_trip"
+	LintCheckCycle                    = "cycle"
+	LintCheckMissingInverse           = "missing_inverse"
+	LintCheckUnreachablePreferredUnit = "unreachable_preferred_unit"
+	LintCheckDuplicateConversion      = "duplicate_conversion"
+	LintCheckIsolatedUnit             = "isolated_unit"
+)
+
+// DefaultLintTolerance is the relative difference that Lint allows in between two results that should be the same
+const DefaultLintTolerance = 1e-12
+
+// lintSamples are the magnitudes that conversion paths are compared with, two samples so that both the scale and any offset of a formula are covered
+var lintSamples = []float64{1, 1000}
+
+// LintIssue is a problem with a configuration that Converter.Test does not catch, such as conversions that are not each others inverse
+type LintIssue struct {
+	Check   string   `json:"check"`
+	Units   []string `json:"units"`
+	Message string   `json:"message"`
+}
+
+func (issue LintIssue) String() string {
+	return fmt.Sprintf("%s: %s", issue.Check, issue.Message)
+}
+
+func lintClose(a float64, b float64, tolerance float64) bool {
+	return a == b || math.Abs(a-b) <= tolerance*math.Max(math.Abs(a), math.Abs(b))
+}
+
+func lintPath(units []string) string {
+	return strings.Join(units, " -> ")
+}
+
+// convertThrough converts magnitude with each conversion in path, ok is false if any of them fails
+func convertThrough(magnitude float64, path ...*Conversion) (output float64, ok bool) {
+	output = magnitude
+	for _, conversion := range path {
+		quantity, err := conversion.Convert(Quantity{Magnitude: output, Unit: conversion.From})
+		if err != nil || math.IsNaN(quantity.Magnitude) || math.IsInf(quantity.Magnitude, 0) {
+			return
+		}
+
+		output = quantity.Magnitude
+	}
+
+	ok = true
+
+	return
+}
+
+// Lint checks that the conversions agree with each other within tolerance (a relative difference, use DefaultLintTolerance unless there is a reason not to), that each conversion has an inverse, that preferred units can be reached and that there are no duplicated conversions or units without conversions
+func (converter *Converter) Lint(tolerance float64) (issues []LintIssue) {
+	issues = []LintIssue{}
+	issues = append(issues, converter.lintRoundTrips(tolerance)...)
+	issues = append(issues, converter.lintCycles(tolerance)...)
+	issues = append(issues, converter.lintMissingInverses()...)
+	issues = append(issues, converter.lintUnreachablePreferredUnits()...)
+	issues = append(issues, converter.lintDuplicateConversions()...)
+	issues = append(issues, converter.lintIsolatedUnits()...)
+
+	return
+}
+
+// lintRoundTrips converts from A to B and back to A for each pair of conversions that are each others inverse
+func (converter *Converter) lintRoundTrips(tolerance float64) (issues []LintIssue) {
+	for first := range converter.Conversions {
+		for second := first + 1; second < len(converter.Conversions); second++ {
+			there := &converter.Conversions[first]
+			back := &converter.Conversions[second]
+			if there.From != back.To || there.To != back.From {
+				continue
+			}
+
+			samples := append([]float64{}, lintSamples...)
+			for _, fixture := range there.TestFixtures {
+				samples = append(samples, fixture.Input)
+			}
+
+			for _, sample := range samples {
+				output, ok := convertThrough(sample, there, back)
+				if ok && !lintClose(sample, output, tolerance) {
+					issues = append(issues, LintIssue{
+						Check:   LintCheckRoundTrip,
+						Units:   []string{there.From, there.To},
+						Message: fmt.Sprintf("Converting %v %s to %s with %q and back with %q gives %v %s", sample, there.From, there.To, there.Description(), back.Description(), output, there.From),
+					})
+					break
+				}
+			}
+		}
+	}
+
+	return
+}
+
+// lintCycles compares each conversion with the path found to its to unit by a breadth first search from every unit, if the graph has a cycle where the conversions do not cancel out each other at least one such comparison differs. Each set of units in a cycle is reported once
+func (converter *Converter) lintCycles(tolerance float64) (issues []LintIssue) {
+	reported := map[string]bool{}
+	for _, root := range converter.unitNames() {
+		parents := map[string]*Conversion{}
+		visited := map[string]bool{root: true}
+		order := []string{root}
+		for index := 0; index < len(order); index++ {
+			for _, conversion := range converter.filterConversionsByFrom(order[index], nil) {
+				if !visited[conversion.To] {
+					visited[conversion.To] = true
+					parents[conversion.To] = conversion
+					order = append(order, conversion.To)
+				}
+			}
+		}
+
+		treePath := func(unit string) (path []*Conversion) {
+			for parent := parents[unit]; parent != nil; parent = parents[parent.From] {
+				path = append([]*Conversion{parent}, path...)
+			}
+
+			return
+		}
+
+		for _, unit := range order {
+			for _, conversion := range converter.filterConversionsByFrom(unit, nil) {
+				parent := parents[unit]
+				if conversion == parents[conversion.To] || (parent != nil && parent.From == conversion.To) {
+					continue
+				}
+
+				issue, found := lintComparePaths(append(treePath(unit), conversion), treePath(conversion.To), tolerance)
+				if found && !reported[strings.Join(issue.Units, "\x00")] {
+					reported[strings.Join(issue.Units, "\x00")] = true
+					issues = append(issues, issue)
+				}
+			}
+		}
+	}
+
+	return
+}
+
+// lintComparePaths reports an issue if two paths from the same unit to the same unit give different results
+func lintComparePaths(first []*Conversion, second []*Conversion, tolerance float64) (issue LintIssue, found bool) {
+	// Drop the common start of the paths so that only the cycle is reported
+	for len(first) > 0 && len(second) > 0 && first[0] == second[0] {
+		first = first[1:]
+		second = second[1:]
+	}
+
+	units := func(path []*Conversion, from string) (units []string) {
+		units = []string{from}
+		for _, conversion := range path {
+			units = append(units, conversion.To)
+		}
+
+		return
+	}
+
+	from := first[0].From
+	for _, sample := range lintSamples {
+		firstOutput, firstOK := convertThrough(sample, first...)
+		secondOutput, secondOK := convertThrough(sample, second...)
+		if firstOK && secondOK && !lintClose(firstOutput, secondOutput, tolerance) {
+			firstUnits := units(first, from)
+			secondUnits := units(second, from)
+			cycle := map[string]bool{}
+			for _, unit := range append(firstUnits, secondUnits...) {
+				cycle[unit] = true
+			}
+
+			issue = LintIssue{
+				Check:   LintCheckCycle,
+				Message: fmt.Sprintf("Converting %v %s through %s gives %v but through %s gives %v", sample, from, lintPath(firstUnits), firstOutput, lintPath(secondUnits), secondOutput),
+			}
+			for unit := range cycle {
+				issue.Units = append(issue.Units, unit)
+			}
+			sort.Strings(issue.Units)
+			found = true
+
+			return
+		}
+	}
+
+	return
+}
+
+func (converter *Converter) hasConversion(from string, to string) bool {
+	for index := range converter.Conversions {
+		if converter.Conversions[index].From == from && converter.Conversions[index].To == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (converter *Converter) lintMissingInverses() (issues []LintIssue) {
+	for index := range converter.Conversions {
+		conversion := &converter.Conversions[index]
+		if !converter.hasConversion(conversion.To, conversion.From) {
+			issues = append(issues, LintIssue{
+				Check:   LintCheckMissingInverse,
+				Units:   []string{conversion.From, conversion.To},
+				Message: fmt.Sprintf("There is a conversion from %q to %q but none from %q to %q", conversion.From, conversion.To, conversion.To, conversion.From),
+			})
+		}
+	}
+
+	return
+}
+
+func (converter *Converter) lintUnreachablePreferredUnits() (issues []LintIssue) {
+	for _, unit := range converter.PreferredUnits {
+		reachable := false
+		for index := range converter.Conversions {
+			if converter.Conversions[index].To == unit {
+				reachable = true
+				break
+			}
+		}
+
+		if !reachable {
+			issues = append(issues, LintIssue{
+				Check:   LintCheckUnreachablePreferredUnit,
+				Units:   []string{unit},
+				Message: fmt.Sprintf("The preferred unit %q can not be converted to from any other unit", unit),
+			})
+		}
+	}
+
+	return
+}
+
+func (converter *Converter) lintDuplicateConversions() (issues []LintIssue) {
+	counts := map[[2]string]int{}
+	pairs := [][2]string{}
+	for index := range converter.Conversions {
+		pair := [2]string{converter.Conversions[index].From, converter.Conversions[index].To}
+		if counts[pair] == 0 {
+			pairs = append(pairs, pair)
+		}
+		counts[pair]++
+	}
+
+	for _, pair := range pairs {
+		if counts[pair] > 1 {
+			issues = append(issues, LintIssue{
+				Check:   LintCheckDuplicateConversion,
+				Units:   []string{pair[0], pair[1]},
+				Message: fmt.Sprintf("There are %d conversions from %q to %q, only the first one is used", counts[pair], pair[0], pair[1]),
+			})
+		}
+	}
+
+	return
+}
+
+func (converter *Converter) lintIsolatedUnits() (issues []LintIssue) {
+	for _, definition := range converter.UnitDefinitions {
+		isolated := true
+		for index := range converter.Conversions {
+			if converter.Conversions[index].From == definition.Name || converter.Conversions[index].To == definition.Name {
+				isolated = false
+				break
+			}
+		}
+
+		if isolated {
+			issues = append(issues, LintIssue{
+				Check:   LintCheckIsolatedUnit,
+				Units:   []string{definition.Name},
+				Message: fmt.Sprintf("The unit %q is defined but has no conversions", definition.Name),
+			})
+		}
+	}
+
+	return
+}
@@ -0,0 +1,134 @@
+package unitconversion
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func lintChecks(issues []LintIssue) (checks []string) {
+	checks = []string{}
+	for _, issue := range issues {
+		checks = append(checks, issue.Check)
+	}
+
+	return
+}
+
+func TestConverterLintFromYAML(test *testing.T) {
+	raw, err := ioutil.ReadFile("converter.yml")
+	assert.NoError(test, err)
+
+	converter, err := NewConverterFromYAML(raw)
+	assert.NoError(test, err)
+
+	roundTrips := [][]string{}
+	for _, issue := range converter.Lint(DefaultLintTolerance) {
+		assert.NotEqual(test, LintCheckCycle, issue.Check, issue.Message)
+		if issue.Check == LintCheckRoundTrip {
+			roundTrips = append(roundTrips, issue.Units)
+		}
+	}
+
+	assert.Contains(test, roundTrips, []string{"m", "in"})
+}
+
+func TestConverterLintRoundTrip(test *testing.T) {
+	converter := Converter{
+		Conversions: []Conversion{
+			Conversion{From: "m", To: "in", Formula: "magnitude * 39.3700787"},
+			Conversion{From: "in", To: "m", Formula: "magnitude * 0.0254"},
+		},
+	}
+
+	issues := converter.Lint(DefaultLintTolerance)
+	assert.Equal(test, []string{LintCheckRoundTrip}, lintChecks(issues))
+	assert.Equal(test, []string{"m", "in"}, issues[0].Units)
+
+	assert.Empty(test, converter.Lint(1e-6))
+}
+
+func TestConverterLintCycle(test *testing.T) {
+	converter := Converter{
+		Conversions: []Conversion{
+			Conversion{From: "cm", To: "m", Formula: "magnitude / 100"},
+			Conversion{From: "m", To: "mm", Formula: "magnitude * 1000"},
+			Conversion{From: "mm", To: "cm", Formula: "magnitude / 100"},
+		},
+	}
+
+	issues := converter.Lint(DefaultLintTolerance)
+	assert.Contains(test, lintChecks(issues), LintCheckCycle)
+	for _, issue := range issues {
+		if issue.Check == LintCheckCycle {
+			assert.Equal(test, []string{"cm", "m", "mm"}, issue.Units)
+		}
+	}
+
+	converter.Conversions[2] = Conversion{From: "mm", To: "cm", Formula: "magnitude / 10"}
+	assert.NotContains(test, lintChecks(converter.Lint(DefaultLintTolerance)), LintCheckCycle)
+}
+
+func TestConverterLintCycleWithOffset(test *testing.T) {
+	converter := Converter{
+		Conversions: []Conversion{
+			Conversion{From: "°C", To: "K", Formula: "magnitude + 273.15"},
+			Conversion{From: "K", To: "°C", Formula: "magnitude - 273.15"},
+			Conversion{From: "°C", To: "°F", Formula: "magnitude * 9 / 5 + 32"},
+			Conversion{From: "°F", To: "°C", Formula: "(magnitude - 32) * 5 / 9"},
+			Conversion{From: "K", To: "°F", Formula: "magnitude * 9 / 5"},
+			Conversion{From: "°F", To: "K", Formula: "magnitude * 5 / 9"},
+		},
+	}
+
+	assert.Contains(test, lintChecks(converter.Lint(1e-9)), LintCheckCycle)
+
+	converter.Conversions[4] = Conversion{From: "K", To: "°F", Formula: "(magnitude - 273.15) * 9 / 5 + 32"}
+	converter.Conversions[5] = Conversion{From: "°F", To: "K", Formula: "(magnitude - 32) * 5 / 9 + 273.15"}
+	assert.Empty(test, converter.Lint(1e-9))
+}
+
+func TestConverterLintCycleIntoVisitedSink(test *testing.T) {
+	// The sink a is searched from first as it sorts first, the two paths into it from b must still be compared
+	converter := Converter{
+		Conversions: []Conversion{
+			Conversion{From: "b", To: "c", Formula: "magnitude * 2"},
+			Conversion{From: "b", To: "d", Formula: "magnitude * 3"},
+			Conversion{From: "c", To: "a", Formula: "magnitude * 3"},
+			Conversion{From: "d", To: "a", Formula: "magnitude * 3"},
+		},
+	}
+
+	issues := []LintIssue{}
+	for _, issue := range converter.Lint(DefaultLintTolerance) {
+		if issue.Check == LintCheckCycle {
+			issues = append(issues, issue)
+		}
+	}
+	assert.Len(test, issues, 1)
+	assert.Equal(test, []string{"a", "b", "c", "d"}, issues[0].Units)
+
+	converter.Conversions[3] = Conversion{From: "d", To: "a", Formula: "magnitude * 2"}
+	assert.NotContains(test, lintChecks(converter.Lint(DefaultLintTolerance)), LintCheckCycle)
+}
+
+func TestConverterLintStructure(test *testing.T) {
+	converter := Converter{
+		PreferredUnits:  []string{"m", "l"},
+		UnitDefinitions: []UnitDefinition{UnitDefinition{Name: "l"}},
+		Conversions: []Conversion{
+			Conversion{From: "cm", To: "m", Formula: "magnitude / 100"},
+			Conversion{From: "cm", To: "m", Formula: "magnitude / 100"},
+			Conversion{From: "m", To: "cm", Formula: "magnitude * 100"},
+			Conversion{From: "in", To: "m", Formula: "magnitude * 0.0254"},
+		},
+	}
+
+	assert.Equal(test, []string{
+		LintCheckMissingInverse,
+		LintCheckUnreachablePreferredUnit,
+		LintCheckDuplicateConversion,
+		LintCheckIsolatedUnit,
+	}, lintChecks(converter.Lint(DefaultLintTolerance)))
+}
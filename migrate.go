@@ -0,0 +1,121 @@
+package unitconversion
+
+import (
+	"bytes"
+	"strconv"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// CurrentConfigVersion is the version of the configuration format written by MigrateConfig, files without a version: key are version 0
+const CurrentConfigVersion = 1
+
+// configMigration upgrades the root mapping of a configuration file from one version to the next, errors should be created with configSource.errorf so that they have a position
+type configMigration func(file string, document *yaml.Node) []*ConfigError
+
+// configMigrations has the migration from each version to the next, configMigrations[0] upgrades version 0 to version 1 and so on
+var configMigrations = []configMigration{
+	migrateConfigVersion0,
+}
+
+// migrateConfigVersion0 upgrades files that were written before the version: key was added, the format is otherwise the same as version 1
+func migrateConfigVersion0(file string, document *yaml.Node) (configErrors []*ConfigError) {
+	return
+}
+
+// mappingValue returns the key and value nodes for key in mapping, both are nil if key is missing
+func mappingValue(mapping *yaml.Node, key string) (keyNode *yaml.Node, valueNode *yaml.Node) {
+	for index := 0; index+1 < len(mapping.Content); index += 2 {
+		if mapping.Content[index].Value == key {
+			keyNode, valueNode = mapping.Content[index], mapping.Content[index+1]
+			return
+		}
+	}
+
+	return
+}
+
+// configVersion reads the version: key of a configuration file
+func configVersion(file string, document *yaml.Node) (version int, configErrors []*ConfigError) {
+	_, node := mappingValue(document, "version")
+	if node == nil {
+		return
+	}
+
+	source := sourceOf(file, node)
+	version, err := strconv.Atoi(node.Value)
+	if err != nil || node.Kind != yaml.ScalarNode || node.Tag != "!!int" {
+		configErrors = append(configErrors, source.errorf("The version must be a whole number, got %s", yamlKind(node)))
+		return
+	}
+
+	if version < 0 || version > CurrentConfigVersion {
+		configErrors = append(configErrors, source.errorf("The configuration version %d is not supported, the latest supported version is %d", version, CurrentConfigVersion))
+	}
+
+	return
+}
+
+// migrateDocument upgrades the root mapping of a configuration file in place to CurrentConfigVersion and sets its version: key
+func migrateDocument(file string, document *yaml.Node) (configErrors []*ConfigError) {
+	version, configErrors := configVersion(file, document)
+	if len(configErrors) > 0 {
+		return
+	}
+
+	for ; version < CurrentConfigVersion; version++ {
+		configErrors = configMigrations[version](file, document)
+		if len(configErrors) > 0 {
+			return
+		}
+	}
+
+	value := strconv.Itoa(CurrentConfigVersion)
+	if _, node := mappingValue(document, "version"); node != nil {
+		node.Value = value
+		return
+	}
+
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"}
+	valueNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: value}
+
+	// The comments at the top of the file stay at the top
+	if len(document.Content) > 0 {
+		keyNode.HeadComment = document.Content[0].HeadComment
+		document.Content[0].HeadComment = ""
+	}
+
+	document.Content = append([]*yaml.Node{keyNode, valueNode}, document.Content...)
+
+	return
+}
+
+// MigrateConfig rewrites a configuration file of any supported version into the format of CurrentConfigVersion, comments are kept, includes are not followed as each file has its own version
+func MigrateConfig(raw []byte) (migrated []byte, err error) {
+	var root yaml.Node
+	if err = yaml.Unmarshal(raw, &root); err != nil {
+		err = ConfigErrors(yamlErrors("", err))
+		return
+	}
+
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		err = ConfigErrors{&ConfigError{Message: "Expected a mapping at the top of the configuration"}}
+		return
+	}
+
+	if configErrors := migrateDocument("", root.Content[0]); len(configErrors) > 0 {
+		err = ConfigErrors(configErrors)
+		return
+	}
+
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(&root); err != nil {
+		return
+	}
+
+	migrated = buffer.Bytes()
+
+	return
+}
@@ -0,0 +1,82 @@
+package unitconversion
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestConfigMigrationsReachCurrentVersion(test *testing.T) {
+	assert.Len(test, configMigrations, CurrentConfigVersion)
+}
+
+func TestMigrateConfig(test *testing.T) {
+	migrated, err := MigrateConfig([]byte(`# Lab units
+preferredUnits:
+  - m
+conversions:
+  - from: cm
+    to: m
+    formula: magnitude / 100 # exact
+    testFixtures:
+      - input: 100
+        expected: 1
+`))
+	assert.NoError(test, err)
+	assert.Equal(test, `# Lab units
+version: 1
+preferredUnits:
+  - m
+conversions:
+  - from: cm
+    to: m
+    formula: magnitude / 100 # exact
+    testFixtures:
+      - input: 100
+        expected: 1
+`, string(migrated))
+
+	again, err := MigrateConfig(migrated)
+	assert.NoError(test, err)
+	assert.Equal(test, string(migrated), string(again))
+}
+
+func TestMigrateConfigRunsEachMigrationInOrder(test *testing.T) {
+	original := configMigrations
+	defer func() { configMigrations = original }()
+
+	versions := []int{}
+	configMigrations = []configMigration{
+		func(file string, document *yaml.Node) []*ConfigError {
+			versions = append(versions, 0)
+			return nil
+		},
+	}
+
+	_, err := MigrateConfig([]byte("preferredUnits:\n  - m\n"))
+	assert.NoError(test, err)
+	assert.Equal(test, []int{0}, versions)
+
+	versions = []int{}
+	_, err = MigrateConfig([]byte("version: 1\npreferredUnits:\n  - m\n"))
+	assert.NoError(test, err)
+	assert.Empty(test, versions)
+}
+
+func TestFailMigrateConfigWithUnsupportedVersion(test *testing.T) {
+	_, err := MigrateConfig([]byte("version: 2\npreferredUnits:\n  - m\n"))
+	assert.EqualError(test, err, "1:10: The configuration version 2 is not supported, the latest supported version is 1")
+
+	_, err = MigrateConfig([]byte("version: latest\n"))
+	assert.EqualError(test, err, `1:10: The version must be a whole number, got "latest"`)
+
+	_, err = NewConverterFromYAML([]byte("version: 2\npreferredUnits:\n  - m\n"))
+	assert.EqualError(test, err, "1:10: The configuration version 2 is not supported, the latest supported version is 1")
+}
+
+func TestNewConverterFromYAMLWithoutVersion(test *testing.T) {
+	converter, err := NewConverterFromYAML([]byte("preferredUnits:\n  - m\n"))
+	assert.NoError(test, err)
+	assert.Equal(test, []string{"m"}, converter.PreferredUnits)
+}
@@ -0,0 +1,134 @@
+package unitconversion
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// maxExactDigits is the number of significant decimal digits that always survive being read as a float64
+const maxExactDigits = 15
+
+// parseMagnitude reads a JSON number, or a JSON string holding a number when acceptStrings is true, quoted reports if the magnitude was a string
+func parseMagnitude(raw json.RawMessage, acceptStrings bool) (magnitude float64, quoted bool, ok bool) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return
+	}
+
+	var number json.Number
+	if raw[0] == '"' {
+		if !acceptStrings {
+			return
+		}
+
+		var text string
+		if json.Unmarshal(raw, &text) != nil {
+			return
+		}
+
+		// Only accept strings that are valid JSON numbers, e.g. "10.5" but not "10,5" or "0x10"
+		if json.Unmarshal([]byte(text), &number) != nil {
+			return
+		}
+
+		quoted = true
+	} else if json.Unmarshal(raw, &number) != nil {
+		return
+	}
+
+	magnitude, err := number.Float64()
+	if err != nil {
+		return 0, false, false
+	}
+
+	ok = true
+
+	return
+}
+
+// formatMagnitude writes a magnitude as a raw JSON number or, when quoted is true, as a JSON string, the shortest representation that reads back to the same float64 is used
+func formatMagnitude(magnitude float64, quoted bool) (raw string, err error) {
+	if math.IsNaN(magnitude) || math.IsInf(magnitude, 0) {
+		err = fmt.Errorf("Unable to write magnitude %v as JSON", magnitude)
+		return
+	}
+
+	// Same choice of format as encoding/json so that numbers are written as they would have been by json.Marshal
+	format := byte('f')
+	if absolute := math.Abs(magnitude); absolute != 0 && (absolute < 1e-6 || absolute >= 1e21) {
+		format = 'e'
+	}
+
+	raw = strconv.FormatFloat(magnitude, format, -1, 64)
+	if quoted {
+		raw = strconv.Quote(raw)
+	}
+
+	return
+}
+
+// decimalDigits splits a JSON number into its significant digits with the sign and the power of ten they are multiplied with, e.g. "-12.50" gives "-125" and -1. ok is false when the exponent is too large to be read
+func decimalDigits(number string) (digits string, exponent int, ok bool) {
+	sign := ""
+	if strings.HasPrefix(number, "-") {
+		sign = "-"
+		number = number[1:]
+	}
+
+	mantissa := number
+	if index := strings.IndexAny(number, "eE"); index >= 0 {
+		mantissa = number[:index]
+		var err error
+		if exponent, err = strconv.Atoi(number[index+1:]); err != nil {
+			return
+		}
+	}
+
+	if index := strings.IndexByte(mantissa, '.'); index >= 0 {
+		exponent -= len(mantissa) - index - 1
+		mantissa = mantissa[:index] + mantissa[index+1:]
+	}
+
+	mantissa = strings.TrimLeft(mantissa, "0")
+	for strings.HasSuffix(mantissa, "0") {
+		mantissa = mantissa[:len(mantissa)-1]
+		exponent++
+	}
+
+	if mantissa == "" {
+		return "0", 0, true
+	}
+
+	return sign + mantissa, exponent, true
+}
+
+// losesPrecision reports if the JSON number or numeric string raw, read as magnitude, has more significant digits than the float64 can hold so that converting it would round it
+func losesPrecision(raw json.RawMessage, magnitude float64) bool {
+	number := string(bytes.TrimSpace(raw))
+	if len(number) <= maxExactDigits {
+		return false
+	}
+
+	if number[0] == '"' {
+		if json.Unmarshal(raw, &number) != nil {
+			return true
+		}
+	}
+
+	digits, exponent, ok := decimalDigits(number)
+	if !ok {
+		return true
+	}
+
+	if len(strings.TrimPrefix(digits, "-")) <= maxExactDigits {
+		return false
+	}
+
+	readDigits, readExponent, _ := decimalDigits(strconv.FormatFloat(magnitude, 'e', -1, 64))
+
+	return digits != readDigits || exponent != readExponent
+}
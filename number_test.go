@@ -0,0 +1,109 @@
+package unitconversion
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMagnitude(test *testing.T) {
+	magnitude, quoted, ok := parseMagnitude(json.RawMessage(`10.5`), false)
+	assert.True(test, ok)
+	assert.False(test, quoted)
+	assert.Equal(test, 10.5, magnitude)
+
+	magnitude, quoted, ok = parseMagnitude(json.RawMessage(`"10.5"`), true)
+	assert.True(test, ok)
+	assert.True(test, quoted)
+	assert.Equal(test, 10.5, magnitude)
+
+	magnitude, quoted, ok = parseMagnitude(json.RawMessage(`-1.5e3`), false)
+	assert.True(test, ok)
+	assert.Equal(test, -1500.0, magnitude)
+}
+
+func TestFailParseMagnitudeWithBadInput(test *testing.T) {
+	inputs := []string{`"10.5"`, `true`, `null`, `{}`, ``}
+	for _, input := range inputs {
+		_, _, ok := parseMagnitude(json.RawMessage(input), false)
+		assert.False(test, ok, input)
+	}
+
+	stringInputs := []string{`"10,5"`, `"0x10"`, `"ten"`, `""`}
+	for _, input := range stringInputs {
+		_, _, ok := parseMagnitude(json.RawMessage(input), true)
+		assert.False(test, ok, input)
+	}
+}
+
+func TestDecimalDigits(test *testing.T) {
+	for number, expected := range map[string]struct {
+		digits   string
+		exponent int
+	}{
+		"0":        {"0", 0},
+		"-12.50":   {"-125", -1},
+		"1200":     {"12", 2},
+		"0.00120":  {"12", -4},
+		"1.5e3":    {"15", 2},
+		"-0.0E-10": {"0", 0},
+	} {
+		digits, exponent, ok := decimalDigits(number)
+		assert.True(test, ok, number)
+		assert.Equal(test, expected.digits, digits, number)
+		assert.Equal(test, expected.exponent, exponent, number)
+	}
+
+	_, _, ok := decimalDigits("1e99999999999999999999")
+	assert.False(test, ok)
+}
+
+func TestLosesPrecision(test *testing.T) {
+	for raw, lossy := range map[string]bool{
+		`10.5`:                     false,
+		`123456789012345`:          false,
+		`10.000000000000000000`:    false,
+		`0.1000000000000000055511`: true,
+		`9007199254740993`:         true,
+		`1234567890123456789`:      true,
+		`"1234567890123456789"`:    true,
+		`0.12345678901234567891`:   true,
+		`1234567890123456789e-3`:   true,
+		`1.234567890123456700e2`:   false,
+	} {
+		magnitude, _, ok := parseMagnitude(json.RawMessage(raw), true)
+		assert.True(test, ok, raw)
+		assert.Equal(test, lossy, losesPrecision(json.RawMessage(raw), magnitude), raw)
+	}
+}
+
+func TestFormatMagnitude(test *testing.T) {
+	cases := map[float64]string{
+		1000000:              "1000000",
+		0.1:                  "0.1",
+		70000.00021345408:    "70000.00021345408",
+		1e21:                 "1e+21",
+		0.0000001:            "1e-07",
+		-25.4:                "-25.4",
+		9007199254740993 * 2: "18014398509481984",
+	}
+
+	for input, expectedOutput := range cases {
+		output, err := formatMagnitude(input, false)
+		assert.NoError(test, err)
+		assert.Equal(test, expectedOutput, output)
+	}
+
+	output, err := formatMagnitude(25.4, true)
+	assert.NoError(test, err)
+	assert.Equal(test, `"25.4"`, output)
+}
+
+func TestFailFormatMagnitudeWithNonFiniteNumbers(test *testing.T) {
+	for _, input := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		_, err := formatMagnitude(input, false)
+		assert.Error(test, err)
+	}
+}
@@ -0,0 +1,90 @@
+package unitconversion
+
+// FixtureResult is the outcome of one ConversionTestFixture, Actual and Deviation are zero when Error is set
+type FixtureResult struct {
+	Input     float64 `json:"input"`
+	Expected  float64 `json:"expected"`
+	Actual    float64 `json:"actual"`
+	Deviation float64 `json:"deviation"`
+	Passed    bool    `json:"passed"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// ConversionTestResult is the outcome of all test fixtures of a Conversion, Error is set when the conversion is not valid, e.g. when it has no test fixtures
+type ConversionTestResult struct {
+	From     string          `json:"from"`
+	To       string          `json:"to"`
+	Formula  string          `json:"formula"`
+	Passed   bool            `json:"passed"`
+	Excluded bool            `json:"excluded"`
+	Fixtures []FixtureResult `json:"fixtures"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// TestReport lists the test result of every conversion of a Converter, including the conversions that were excluded when it was loaded in degraded mode
+type TestReport struct {
+	Passed      int                    `json:"passed"`
+	Failed      int                    `json:"failed"`
+	Excluded    int                    `json:"excluded"`
+	Conversions []ConversionTestResult `json:"conversions"`
+}
+
+// OK reports if every conversion passed its test fixtures
+func (report *TestReport) OK() bool {
+	return report.Failed == 0
+}
+
+// TestResult works as Test but runs every test fixture instead of stopping at the first failure
+func (conversion *Conversion) TestResult() (result ConversionTestResult) {
+	result = ConversionTestResult{From: conversion.From, To: conversion.To, Formula: conversion.Description(), Passed: true, Fixtures: []FixtureResult{}}
+
+	if len(conversion.TestFixtures) == 0 {
+		result.Passed = false
+		result.Error = "The conversion has no test fixtures"
+	}
+
+	for _, fixture := range conversion.TestFixtures {
+		fixtureResult := FixtureResult{Input: fixture.Input, Expected: fixture.Expected}
+		output, err := conversion.Convert(Quantity{Magnitude: fixture.Input, Unit: conversion.From})
+		if err != nil {
+			fixtureResult.Error = err.Error()
+		} else {
+			fixtureResult.Actual = output.Magnitude
+			fixtureResult.Deviation = output.Magnitude - fixture.Expected
+			fixtureResult.Passed = output.Magnitude == fixture.Expected
+		}
+
+		result.Passed = result.Passed && fixtureResult.Passed
+		result.Fixtures = append(result.Fixtures, fixtureResult)
+	}
+
+	return
+}
+
+// TestReport tests every conversion and reports all results, unlike Test it does not stop at the first failure
+func (converter *Converter) TestReport() (report TestReport) {
+	report.Conversions = []ConversionTestResult{}
+
+	for index := range converter.Conversions {
+		report.add(converter.Conversions[index].TestResult())
+	}
+
+	for index := range converter.ExcludedConversions {
+		result := converter.ExcludedConversions[index].TestResult()
+		result.Excluded = true
+		report.Excluded++
+		report.add(result)
+	}
+
+	return
+}
+
+func (report *TestReport) add(result ConversionTestResult) {
+	if result.Passed {
+		report.Passed++
+	} else {
+		report.Failed++
+	}
+
+	report.Conversions = append(report.Conversions, result)
+}
@@ -0,0 +1,87 @@
+package unitconversion
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const reportTestConfig = `preferredUnits:
+  - m
+conversions:
+  - from: km
+    to: m
+    formula: magnitude * 1000
+    testFixtures:
+      - input: 1
+        expected: 1000
+  - from: cm
+    to: m
+    formula: magnitude / 10
+    testFixtures:
+      - input: 100
+        expected: 1
+      - input: 0
+        expected: 0
+`
+
+func TestConversionTestResult(test *testing.T) {
+	conversion := Conversion{From: "cm", To: "m", Formula: "magnitude / 10", TestFixtures: []ConversionTestFixture{
+		{Input: 100, Expected: 1},
+		{Input: 0, Expected: 0},
+	}}
+
+	result := conversion.TestResult()
+	assert.False(test, result.Passed)
+	assert.Equal(test, []FixtureResult{
+		{Input: 100, Expected: 1, Actual: 10, Deviation: 9},
+		{Input: 0, Expected: 0, Actual: 0, Deviation: 0, Passed: true},
+	}, result.Fixtures)
+
+	conversion = Conversion{From: "cm", To: "m", Formula: "magnitude / unknown", TestFixtures: []ConversionTestFixture{{Input: 100, Expected: 1}}}
+	result = conversion.TestResult()
+	assert.False(test, result.Passed)
+	assert.NotEmpty(test, result.Fixtures[0].Error)
+
+	conversion = Conversion{From: "cm", To: "m", Formula: "magnitude / 100"}
+	result = conversion.TestResult()
+	assert.False(test, result.Passed)
+	assert.Equal(test, "The conversion has no test fixtures", result.Error)
+}
+
+func TestLoadConverterFilesDegraded(test *testing.T) {
+	directory := writeConfigFiles(test, map[string]string{"converter.yml": reportTestConfig})
+	defer os.RemoveAll(directory)
+	path := filepath.Join(directory, "converter.yml")
+
+	_, _, err := LoadConverterFiles(LoadOptions{}, path)
+	assert.Error(test, err)
+
+	converter, _, err := LoadConverterFiles(LoadOptions{Degraded: true}, path)
+	assert.NoError(test, err)
+	assert.Len(test, converter.Conversions, 1)
+	assert.Len(test, converter.ExcludedConversions, 1)
+
+	_, err = converter.Convert(Quantity{Magnitude: 1, Unit: "cm"}, "m")
+	assert.Error(test, err)
+
+	report := converter.TestReport()
+	assert.False(test, report.OK())
+	assert.Equal(test, 1, report.Passed)
+	assert.Equal(test, 1, report.Failed)
+	assert.Equal(test, 1, report.Excluded)
+	assert.True(test, report.Conversions[1].Excluded)
+	assert.Equal(test, "cm", report.Conversions[1].From)
+}
+
+func TestTestReport(test *testing.T) {
+	converter, err := LoadConverter("converter.yml")
+	assert.NoError(test, err)
+
+	report := converter.TestReport()
+	assert.True(test, report.OK())
+	assert.Equal(test, len(converter.Conversions), report.Passed)
+	assert.Zero(test, report.Excluded)
+}
@@ -0,0 +1,148 @@
+package unitconversion
+
+import (
+	"fmt"
+	"math"
+)
+
+// TableInterpolation selects how a ConversionTable converts magnitudes in between the inputs of its values
+type TableInterpolation string
+
+// Interpolations for ConversionTable.Interpolation, TableInterpolationNone is used when it is empty
+const (
+	// TableInterpolationNone uses the output of the closest input below the magnitude, for sizes and scales such as shoe sizes or Beaufort wind force
+	TableInterpolationNone TableInterpolation = "none"
+	// TableInterpolationLinear draws a straight line in between the two closest values
+	TableInterpolationLinear TableInterpolation = "linear"
+	// TableInterpolationLog grows the output by the same factor for each step of the input in between the two closest values, for scales such as AWG wire gauges, the outputs must be above zero
+	TableInterpolationLog TableInterpolation = "log"
+)
+
+// TableOutOfRange selects what a ConversionTable does with a magnitude below the first or above the last input
+type TableOutOfRange string
+
+// Behaviours for ConversionTable.OutOfRange, TableOutOfRangeError is used when it is empty
+const (
+	// TableOutOfRangeError fails the conversion
+	TableOutOfRangeError TableOutOfRange = "error"
+	// TableOutOfRangeClamp uses the output of the first or last value
+	TableOutOfRangeClamp TableOutOfRange = "clamp"
+	// TableOutOfRangeExtrapolate continues the interpolation of the first or last two values, with TableInterpolationNone it works as TableOutOfRangeClamp
+	TableOutOfRangeExtrapolate TableOutOfRange = "extrapolate"
+)
+
+// TableValue is one row of a ConversionTable, Input converts to Output
+type TableValue struct {
+	Input  float64 `yaml:"input"`
+	Output float64 `yaml:"output"`
+}
+
+// ConversionTable converts magnitudes by looking them up in a table of values instead of with a formula, the values must be sorted by input
+type ConversionTable struct {
+	Interpolation TableInterpolation `yaml:"interpolation"`
+	OutOfRange    TableOutOfRange    `yaml:"outOfRange"`
+	Values        []TableValue       `yaml:"values"`
+}
+
+// Check verifies that the table has values sorted by input and known interpolation and out of range settings
+func (table *ConversionTable) Check() (err error) {
+	switch table.Interpolation {
+	case "", TableInterpolationNone, TableInterpolationLinear, TableInterpolationLog:
+	default:
+		err = fmt.Errorf("The interpolation %q is not supported, use none, linear or log", table.Interpolation)
+		return
+	}
+
+	switch table.OutOfRange {
+	case "", TableOutOfRangeError, TableOutOfRangeClamp, TableOutOfRangeExtrapolate:
+	default:
+		err = fmt.Errorf("The out of range behaviour %q is not supported, use error, clamp or extrapolate", table.OutOfRange)
+		return
+	}
+
+	if len(table.Values) == 0 {
+		err = fmt.Errorf("The table has no values")
+		return
+	}
+
+	for index, value := range table.Values {
+		if index > 0 && value.Input <= table.Values[index-1].Input {
+			err = fmt.Errorf("The table values must be sorted by input, %v comes after %v", value.Input, table.Values[index-1].Input)
+			return
+		}
+
+		if table.Interpolation == TableInterpolationLog && value.Output <= 0 {
+			err = fmt.Errorf("The table output %v must be above zero for log interpolation", value.Output)
+			return
+		}
+	}
+
+	return
+}
+
+// String describes the table as its interpolation and number of values, e.g. "table (linear, 7 values)"
+func (table *ConversionTable) String() string {
+	interpolation := table.Interpolation
+	if interpolation == "" {
+		interpolation = TableInterpolationNone
+	}
+
+	return fmt.Sprintf("table (%s, %d values)", interpolation, len(table.Values))
+}
+
+// ConvertMagnitude looks up magnitude in the table, which makes ConversionTable a MagnitudeConverter. The table is expected to have passed Check, as Conversion.Test does
+func (table *ConversionTable) ConvertMagnitude(magnitude float64) (output float64, err error) {
+	if len(table.Values) == 0 {
+		err = fmt.Errorf("The table has no values")
+		return
+	}
+
+	values := table.Values
+	first, last := values[0], values[len(values)-1]
+	if magnitude < first.Input || magnitude > last.Input {
+		switch table.OutOfRange {
+		case TableOutOfRangeClamp:
+			output = first.Output
+			if magnitude > last.Input {
+				output = last.Output
+			}
+			return
+		case TableOutOfRangeExtrapolate:
+		default:
+			err = fmt.Errorf("The magnitude %v is outside of the table from %v to %v", magnitude, first.Input, last.Input)
+			return
+		}
+	}
+
+	// index is the value that starts the segment the magnitude is in, the first and last segments are used outside of the table
+	index := 0
+	for index+1 < len(values)-1 && values[index+1].Input <= magnitude {
+		index++
+	}
+
+	if len(values) == 1 || table.Interpolation == "" || table.Interpolation == TableInterpolationNone {
+		output = values[index].Output
+		if magnitude >= last.Input {
+			output = last.Output
+		}
+		return
+	}
+
+	low, high := values[index], values[index+1]
+	position := (magnitude - low.Input) / (high.Input - low.Input)
+	if table.Interpolation == TableInterpolationLog {
+		output = low.Output * math.Pow(high.Output/low.Output, position)
+	} else {
+		output = low.Output + (high.Output-low.Output)*position
+	}
+
+	// The outputs in the table are used as they are, without rounding errors from the interpolation
+	switch magnitude {
+	case low.Input:
+		output = low.Output
+	case high.Input:
+		output = high.Output
+	}
+
+	return
+}
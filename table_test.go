@@ -0,0 +1,163 @@
+package unitconversion
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConversionTableConvertMagnitude(test *testing.T) {
+	values := []TableValue{{Input: 0, Output: 1}, {Input: 10, Output: 2}, {Input: 20, Output: 8}}
+
+	for _, example := range []struct {
+		table     ConversionTable
+		magnitude float64
+		expected  float64
+	}{
+		{ConversionTable{Values: values}, 0, 1},
+		{ConversionTable{Values: values}, 5, 1},
+		{ConversionTable{Values: values}, 10, 2},
+		{ConversionTable{Values: values}, 19, 2},
+		{ConversionTable{Values: values}, 20, 8},
+		{ConversionTable{Interpolation: TableInterpolationLinear, Values: values}, 5, 1.5},
+		{ConversionTable{Interpolation: TableInterpolationLinear, Values: values}, 15, 5},
+		{ConversionTable{Interpolation: TableInterpolationLinear, Values: values}, 20, 8},
+		{ConversionTable{Interpolation: TableInterpolationLog, Values: values}, 15, 4},
+		{ConversionTable{Interpolation: TableInterpolationLinear, OutOfRange: TableOutOfRangeClamp, Values: values}, -5, 1},
+		{ConversionTable{Interpolation: TableInterpolationLinear, OutOfRange: TableOutOfRangeClamp, Values: values}, 25, 8},
+		{ConversionTable{Interpolation: TableInterpolationLinear, OutOfRange: TableOutOfRangeExtrapolate, Values: values}, -10, 0},
+		{ConversionTable{Interpolation: TableInterpolationLinear, OutOfRange: TableOutOfRangeExtrapolate, Values: values}, 25, 11},
+		{ConversionTable{Interpolation: TableInterpolationLog, OutOfRange: TableOutOfRangeExtrapolate, Values: values}, 30, 32},
+		{ConversionTable{OutOfRange: TableOutOfRangeExtrapolate, Values: values}, 30, 8},
+		{ConversionTable{Interpolation: TableInterpolationLinear, Values: values[:1]}, 0, 1},
+	} {
+		output, err := example.table.ConvertMagnitude(example.magnitude)
+		assert.NoError(test, err)
+		assert.InDelta(test, example.expected, output, 1e-12, "%v with %s interpolation", example.magnitude, example.table.Interpolation)
+	}
+}
+
+func TestFailConversionTableConvertMagnitude(test *testing.T) {
+	values := []TableValue{{Input: 0, Output: 1}, {Input: 10, Output: 2}}
+
+	_, err := (&ConversionTable{Values: values}).ConvertMagnitude(11)
+	assert.EqualError(test, err, "The magnitude 11 is outside of the table from 0 to 10")
+
+	for _, table := range []ConversionTable{
+		{},
+		{Interpolation: "cubic", Values: values},
+		{OutOfRange: "wrap", Values: values},
+		{Values: []TableValue{{Input: 10, Output: 2}, {Input: 0, Output: 1}}},
+		{Interpolation: TableInterpolationLog, Values: []TableValue{{Input: 0, Output: 0}, {Input: 10, Output: 2}}},
+	} {
+		assert.Error(test, table.Check())
+	}
+
+	// Tables are checked once when they are loaded, only a table without values fails when it is used
+	_, err = (&ConversionTable{}).ConvertMagnitude(5)
+	assert.EqualError(test, err, "The table has no values")
+}
+
+func TestConversionsFromYAMLWithTable(test *testing.T) {
+	converter, err := NewConverterFromYAML([]byte(`
+preferredUnits:
+  - beaufort
+conversions:
+  - from: km/h
+    to: m/s
+    formula: magnitude / 3.6
+    testFixtures:
+      - input: 36
+        expected: 10
+  - from: m/s
+    to: beaufort
+    table:
+      outOfRange: clamp
+      values:
+        - input: 0
+          output: 0
+        - input: 0.5
+          output: 1
+        - input: 1.6
+          output: 2
+        - input: 3.4
+          output: 3
+        - input: 5.5
+          output: 4
+        - input: 8
+          output: 5
+        - input: 10.8
+          output: 6
+    testFixtures:
+      - input: 4
+        expected: 3
+      - input: 20
+        expected: 6
+`))
+	assert.NoError(test, err)
+
+	output, err := converter.ConvertToPreferredUnit(Quantity{Magnitude: 36, Unit: "km/h"})
+	assert.NoError(test, err)
+	assert.Equal(test, Quantity{Magnitude: 5, Unit: "beaufort"}, output)
+
+	description := "table (none, 7 values)"
+	assert.Equal(test, description, converter.Conversions[1].Description())
+	assert.Contains(test, converter.GraphDOT(), `"m/s" -> "beaufort" [label="`+description+`"];`)
+	assert.Contains(test, converter.GraphMermaid(), `|"`+description+`"|`)
+	assert.Equal(test, description, converter.Conversions[1].TestResult().Formula)
+
+	result, err := converter.ConvertExplained(Quantity{Magnitude: 36, Unit: "km/h"}, "beaufort")
+	assert.NoError(test, err)
+	assert.Equal(test, description, result.Explanation.Hops[1].Formula)
+
+	jsonConverter := NewJSONConverter(converter)
+	jsonConverter.Annotate = true
+	annotated, errs := jsonConverter.ConvertToPreferredUnits(`{"wind": {"magnitude": 36, "unit": "km/h"}}`)
+	assert.Empty(test, errs)
+	assert.Contains(test, annotated, `"formula":"`+description+`"`)
+}
+
+func TestFailConversionsFromYAMLWithTable(test *testing.T) {
+	_, err := NewConverterFromYAML([]byte(`
+conversions:
+  - from: EU
+    to: US
+    formula: magnitude - 33
+    table:
+      values:
+        - input: 40
+          output: 7
+    testFixtures:
+      - input: 40
+        expected: 7
+  - from: AWG
+    to: mm
+    table:
+      interpolation: cubic
+      values:
+        - input: 0
+          output: 8.251
+    testFixtures:
+      - input: 0
+        expected: 8.251
+  - from: mm
+    to: AWG
+    testFixtures:
+      - input: 8.251
+        expected: 0
+`))
+
+	configErrors, ok := err.(ConfigErrors)
+	assert.True(test, ok)
+
+	messages := []string{}
+	for _, configError := range configErrors {
+		messages = append(messages, configError.Error())
+	}
+
+	assert.Equal(test, []string{
+		`3:5: The conversion from "EU" to "US" has both a formula and a table, only one of them can be used`,
+		`13:5: The interpolation "cubic" is not supported, use none, linear or log`,
+		`23:5: The field "formula" is required`,
+	}, messages)
+}
@@ -0,0 +1,430 @@
+package unitconversion
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// UnitStyle selects how units are written in the output of a conversion
+type UnitStyle string
+
+const (
+	// UnitStyleSymbol writes units as the display symbols used in the conversion configuration, e.g. µg/l
+	UnitStyleSymbol UnitStyle = "symbol"
+	// UnitStyleUCUM writes units as case sensitive UCUM codes, e.g. ug/L
+	UnitStyleUCUM UnitStyle = "ucum"
+)
+
+type ucumAtom struct {
+	code   string
+	symbol string
+	metric bool
+}
+
+type ucumPrefix struct {
+	code   string
+	symbol string
+}
+
+var ucumAtoms = []ucumAtom{
+	{"m", "m", true},
+	{"g", "g", true},
+	{"s", "s", true},
+	{"L", "l", true},
+	{"l", "l", true},
+	{"K", "K", true},
+	{"mol", "mol", true},
+	{"cd", "cd", true},
+	{"A", "A", true},
+	{"rad", "rad", true},
+	{"sr", "sr", true},
+	{"Hz", "Hz", true},
+	{"N", "N", true},
+	{"Pa", "Pa", true},
+	{"J", "J", true},
+	{"W", "W", true},
+	{"C", "C", true},
+	{"V", "V", true},
+	{"Ohm", "Ω", true},
+	{"bar", "bar", true},
+	{"t", "t", true},
+	{"eq", "eq", true},
+	{"osm", "osm", true},
+	{"kat", "kat", true},
+	{"U", "U", true},
+	{"cal", "cal", true},
+	{"m[Hg]", "mHg", true},
+	{"m[H2O]", "mH2O", true},
+	{"Cel", "°C", true},
+	{"[iU]", "IU", true},
+	{"[IU]", "IU", true},
+	{"min", "min", false},
+	{"h", "h", false},
+	{"d", "d", false},
+	{"wk", "wk", false},
+	{"mo", "mo", false},
+	{"a", "a", false},
+	{"%", "%", false},
+	{"[degF]", "°F", false},
+	{"[in_i]", "in", false},
+	{"[ft_i]", "ft", false},
+	{"[yd_i]", "yd", false},
+	{"[mi_i]", "mi", false},
+	{"[nmi_i]", "nmi", false},
+	{"[lb_av]", "lb", false},
+	{"[oz_av]", "oz", false},
+	{"[ston_av]", "st", false},
+	{"[gal_us]", "gal", false},
+	{"[qt_us]", "qt", false},
+	{"[pt_us]", "pt", false},
+	{"[foz_us]", "fl oz", false},
+	{"[Cal]", "Cal", false},
+	{"[psi]", "psi", false},
+	{"[pi]", "π", false},
+	{"10*", "10^", false},
+	{"10^", "10^", false},
+}
+
+var ucumPrefixes = []ucumPrefix{
+	{"Y", "Y"},
+	{"Z", "Z"},
+	{"E", "E"},
+	{"P", "P"},
+	{"T", "T"},
+	{"G", "G"},
+	{"M", "M"},
+	{"k", "k"},
+	{"h", "h"},
+	{"da", "da"},
+	{"d", "d"},
+	{"c", "c"},
+	{"m", "m"},
+	{"u", "µ"},
+	{"n", "n"},
+	{"p", "p"},
+	{"f", "f"},
+	{"a", "a"},
+	{"z", "z"},
+	{"y", "y"},
+}
+
+// ucumDialect holds the lookup tables used to parse either UCUM codes or display symbols into the same UCUMExpression
+type ucumDialect struct {
+	atoms    map[string]ucumAtom
+	prefixes map[string]ucumPrefix
+	keys     []string
+}
+
+var ucumCodeDialect = newUCUMDialect(false)
+var ucumSymbolDialect = newUCUMDialect(true)
+
+func newUCUMDialect(symbols bool) (dialect ucumDialect) {
+	dialect.atoms = make(map[string]ucumAtom, len(ucumAtoms))
+	dialect.prefixes = make(map[string]ucumPrefix, len(ucumPrefixes)+1)
+
+	for _, atom := range ucumAtoms {
+		key := atom.code
+		if symbols {
+			key = atom.symbol
+		}
+
+		if _, exists := dialect.atoms[key]; !exists {
+			dialect.atoms[key] = atom
+		}
+	}
+
+	for _, prefix := range ucumPrefixes {
+		key := prefix.code
+		if symbols {
+			key = prefix.symbol
+		}
+
+		dialect.prefixes[key] = prefix
+	}
+
+	if symbols {
+		// Both the micro sign and the greek letter mu are commonly used as symbol for micro
+		dialect.prefixes["μ"] = dialect.prefixes["µ"]
+		dialect.atoms["L"] = dialect.atoms["l"]
+	}
+
+	for key := range dialect.prefixes {
+		dialect.keys = append(dialect.keys, key)
+	}
+
+	// Longest prefix first so that da is preferred over d
+	sort.Slice(dialect.keys, func(a, b int) bool {
+		return len(dialect.keys[a]) > len(dialect.keys[b])
+	})
+
+	return
+}
+
+// UCUMComponent is one simple unit of a UCUM expression, e.g. the ug in ug/L
+type UCUMComponent struct {
+	Prefix     string
+	Atom       string
+	Exponent   int
+	Factor     int
+	Annotation string
+}
+
+// UCUMExpression is a parsed UCUM unit expression, divisions are represented as components with negative exponents
+type UCUMExpression struct {
+	Components []UCUMComponent
+}
+
+// ParseUCUM parses a case sensitive UCUM code such as ug/L, [in_i] or kg.m/s2
+func ParseUCUM(code string) (expression UCUMExpression, err error) {
+	return ucumCodeDialect.parse(code)
+}
+
+// ParseUnitSymbol parses a unit written with display symbols such as µg/l, in or kg.m/s2
+func ParseUnitSymbol(symbol string) (expression UCUMExpression, err error) {
+	return ucumSymbolDialect.parse(symbol)
+}
+
+// UCUMToSymbol converts a UCUM code into its display symbol, e.g. ug/L into µg/l
+func UCUMToSymbol(code string) (symbol string, err error) {
+	expression, err := ParseUCUM(code)
+	if err != nil {
+		return
+	}
+
+	symbol = expression.Symbol()
+
+	return
+}
+
+// SymbolToUCUM converts a display symbol into its UCUM code, e.g. µg/l into ug/L
+func SymbolToUCUM(symbol string) (code string, err error) {
+	expression, err := ParseUnitSymbol(symbol)
+	if err != nil {
+		return
+	}
+
+	code = expression.Code()
+
+	return
+}
+
+// unitForms returns the UCUM code and display symbol for a unit written in either form, units that cannot be parsed are returned unchanged in both forms
+func unitForms(unit string) (code string, symbol string) {
+	expression, err := ParseUnitSymbol(unit)
+	if err != nil {
+		expression, err = ParseUCUM(unit)
+	}
+
+	if err != nil {
+		return unit, unit
+	}
+
+	return expression.Code(), expression.Symbol()
+}
+
+// Code returns the expression written as a UCUM code
+func (expression UCUMExpression) Code() string {
+	return expression.format(func(component UCUMComponent) string {
+		return component.Prefix + component.Atom
+	})
+}
+
+// Symbol returns the expression written with display symbols
+func (expression UCUMExpression) Symbol() string {
+	return expression.format(func(component UCUMComponent) string {
+		return ucumCodeDialect.prefixes[component.Prefix].symbol + ucumCodeDialect.atoms[component.Atom].symbol
+	})
+}
+
+func (expression UCUMExpression) format(unit func(component UCUMComponent) string) string {
+	numerator := []string{}
+	denominator := []string{}
+
+	for _, component := range expression.Components {
+		text := ""
+		if component.Atom != "" {
+			text = unit(component)
+		} else if component.Factor != 0 {
+			text = strconv.Itoa(component.Factor)
+		}
+
+		exponent := component.Exponent
+		if exponent < 0 {
+			exponent = -exponent
+		}
+
+		if component.Atom != "" && exponent != 1 {
+			text += strconv.Itoa(exponent)
+		}
+
+		if component.Annotation != "" {
+			text += "{" + component.Annotation + "}"
+		}
+
+		if component.Exponent < 0 {
+			denominator = append(denominator, text)
+		} else {
+			numerator = append(numerator, text)
+		}
+	}
+
+	result := strings.Join(numerator, ".")
+	for _, text := range denominator {
+		result += "/" + text
+	}
+
+	return result
+}
+
+func (dialect ucumDialect) parse(text string) (expression UCUMExpression, err error) {
+	if strings.TrimSpace(text) == "" {
+		err = fmt.Errorf("Unable to parse empty unit")
+		return
+	}
+
+	expression.Components, err = dialect.parseTerm(text, text)
+
+	return
+}
+
+// parseTerm splits a term on the . and / operators that are not enclosed in brackets, braces or parentheses
+func (dialect ucumDialect) parseTerm(term string, source string) (components []UCUMComponent, err error) {
+	depth := 0
+	start := 0
+	sign := 1
+
+	flush := func(end int, nextSign int) (err error) {
+		part := term[start:end]
+		if part == "" {
+			if end == 0 && nextSign == -1 {
+				// A leading / is allowed and means the reciprocal of the rest of the term
+				sign = nextSign
+				start = end + 1
+				return
+			}
+
+			return fmt.Errorf("Unable to parse unit %q, missing unit around operator", source)
+		}
+
+		partComponents, err := dialect.parseComponent(part, source)
+		if err != nil {
+			return
+		}
+
+		for _, component := range partComponents {
+			component.Exponent *= sign
+			components = append(components, component)
+		}
+
+		sign = nextSign
+		start = end + 1
+
+		return
+	}
+
+	for index := 0; index < len(term); index++ {
+		switch term[index] {
+		case '[', '{', '(':
+			depth++
+		case ']', '}', ')':
+			depth--
+		case '.', '/':
+			if depth == 0 {
+				nextSign := 1
+				if term[index] == '/' {
+					nextSign = -1
+				}
+
+				err = flush(index, nextSign)
+				if err != nil {
+					return
+				}
+			}
+		}
+	}
+
+	if depth != 0 {
+		err = fmt.Errorf("Unable to parse unit %q, unbalanced brackets", source)
+		return
+	}
+
+	err = flush(len(term), 1)
+
+	return
+}
+
+func (dialect ucumDialect) parseComponent(part string, source string) (components []UCUMComponent, err error) {
+	if strings.HasPrefix(part, "(") && strings.HasSuffix(part, ")") {
+		return dialect.parseTerm(part[1:len(part)-1], source)
+	}
+
+	component := UCUMComponent{Exponent: 1}
+
+	if annotationStart := strings.Index(part, "{"); annotationStart >= 0 && strings.HasSuffix(part, "}") {
+		component.Annotation = part[annotationStart+1 : len(part)-1]
+		part = part[:annotationStart]
+		if part == "" {
+			components = append(components, component)
+			return
+		}
+	}
+
+	if factor, factorErr := strconv.Atoi(part); factorErr == nil && factor > 0 {
+		component.Factor = factor
+		components = append(components, component)
+		return
+	}
+
+	unit := part
+	exponentStart := len(part)
+	for exponentStart > 0 && part[exponentStart-1] >= '0' && part[exponentStart-1] <= '9' {
+		exponentStart--
+	}
+	if exponentStart > 0 && exponentStart < len(part) && (part[exponentStart-1] == '-' || part[exponentStart-1] == '+') {
+		exponentStart--
+	}
+
+	if exponentStart > 0 && exponentStart < len(part) {
+		if prefix, atom, ok := dialect.resolveSimpleUnit(part[:exponentStart]); ok {
+			component.Exponent, _ = strconv.Atoi(part[exponentStart:])
+			component.Prefix = prefix
+			component.Atom = atom
+			components = append(components, component)
+			return
+		}
+	}
+
+	prefix, atom, ok := dialect.resolveSimpleUnit(unit)
+	if !ok {
+		err = fmt.Errorf("Unable to parse unit %q, unknown unit %q", source, unit)
+		return
+	}
+
+	component.Prefix = prefix
+	component.Atom = atom
+	components = append(components, component)
+
+	return
+}
+
+// resolveSimpleUnit returns the UCUM codes for the prefix and atom of a unit written in the dialect
+func (dialect ucumDialect) resolveSimpleUnit(unit string) (prefix string, atom string, ok bool) {
+	if unitAtom, exists := dialect.atoms[unit]; exists {
+		return "", unitAtom.code, true
+	}
+
+	for _, key := range dialect.keys {
+		if !strings.HasPrefix(unit, key) {
+			continue
+		}
+
+		unitAtom, exists := dialect.atoms[unit[len(key):]]
+		if exists && unitAtom.metric {
+			return dialect.prefixes[key].code, unitAtom.code, true
+		}
+	}
+
+	return
+}
@@ -0,0 +1,108 @@
+package unitconversion
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUCUMToSymbol(test *testing.T) {
+	cases := map[string]string{
+		"ug/L":       "µg/l",
+		"ng/mL":      "ng/ml",
+		"[in_i]":     "in",
+		"Cel":        "°C",
+		"kg.m/s2":    "kg.m/s2",
+		"mm[Hg]":     "mmHg",
+		"10*3/uL":    "10^3/µl",
+		"/min":       "/min",
+		"dam":        "dam",
+		"{cells}/uL": "{cells}/µl",
+		"(kg.m)/s2":  "kg.m/s2",
+		"mg/(kg.d)":  "mg/kg/d",
+	}
+
+	for input, expectedOutput := range cases {
+		output, err := UCUMToSymbol(input)
+		assert.NoError(test, err, input)
+		assert.Equal(test, expectedOutput, output, input)
+	}
+}
+
+func TestSymbolToUCUM(test *testing.T) {
+	cases := map[string]string{
+		"µg/l":  "ug/L",
+		"μg/l":  "ug/L",
+		"ng/ml": "ng/mL",
+		"in":    "[in_i]",
+		"ft":    "[ft_i]",
+		"lb":    "[lb_av]",
+		"°C":    "Cel",
+		"km":    "km",
+		"m2":    "m2",
+	}
+
+	for input, expectedOutput := range cases {
+		output, err := SymbolToUCUM(input)
+		assert.NoError(test, err, input)
+		assert.Equal(test, expectedOutput, output, input)
+	}
+}
+
+func TestParseUCUM(test *testing.T) {
+	expectedOutput := UCUMExpression{
+		Components: []UCUMComponent{
+			UCUMComponent{Prefix: "k", Atom: "g", Exponent: 1},
+			UCUMComponent{Atom: "m", Exponent: 1},
+			UCUMComponent{Atom: "s", Exponent: -2},
+		},
+	}
+
+	output, err := ParseUCUM("kg.m/s2")
+
+	assert.NoError(test, err)
+	assert.Equal(test, expectedOutput, output)
+}
+
+func TestFailParseUCUMWithBadCodes(test *testing.T) {
+	inputs := []string{"", "ug/", "kg..m", "[in_i", "xyz", "µg", "k[in_i]"}
+
+	for _, input := range inputs {
+		_, err := ParseUCUM(input)
+		assert.Error(test, err, input)
+	}
+}
+
+func TestConverterConvertWithUCUMCodes(test *testing.T) {
+	converter := Converter{
+		Conversions: []Conversion{
+			Conversion{From: "µg/l", To: "ng/ml", Formula: "magnitude"},
+			Conversion{From: "m", To: "in", Formula: "magnitude * 39.3700787"},
+		},
+	}
+
+	output, err := converter.Convert(Quantity{Magnitude: 3, Unit: "ug/L"}, "ng/mL")
+	assert.NoError(test, err)
+	assert.Equal(test, Quantity{Magnitude: 3, Unit: "ng/ml"}, output)
+
+	converter.UnitStyle = UnitStyleUCUM
+	output, err = converter.Convert(Quantity{Magnitude: 1, Unit: "m"}, "[in_i]")
+	assert.NoError(test, err)
+	assert.Equal(test, Quantity{Magnitude: 39.3700787, Unit: "[in_i]"}, output)
+}
+
+func TestFailConverterFromYAMLWithBadUnitStyle(test *testing.T) {
+	input := `
+unitStyle: roman
+conversions:
+  - from: m
+    to: km
+    formula: magnitude / 1000
+    testFixtures:
+      - input: 1000
+        expected: 1
+`
+	_, err := NewConverterFromYAML([]byte(input))
+
+	assert.Error(test, err)
+}
@@ -0,0 +1,224 @@
+package unitconversion
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// UnitDefinition describes a unit with the dimension it measures and alternative names that it can be given as
+type UnitDefinition struct {
+	Name      string   `yaml:"name" json:"name" validate:"required"`
+	Dimension string   `yaml:"dimension" json:"dimension,omitempty"`
+	Aliases   []string `yaml:"aliases" json:"aliases"`
+}
+
+// testUnits verifies that no unit name or alias in Converter.UnitDefinitions is used for more than one unit, index is the definition that reuses a name
+func (converter *Converter) testUnits() (index int, err error) {
+	owners := map[string]string{}
+	for definitionIndex, definition := range converter.UnitDefinitions {
+		for _, name := range append([]string{definition.Name}, definition.Aliases...) {
+			if owner, taken := owners[name]; taken && owner != definition.Name {
+				index = definitionIndex
+				err = fmt.Errorf("The unit name %q is used by both %q and %q", name, owner, definition.Name)
+				return
+			}
+
+			owners[name] = definition.Name
+		}
+	}
+
+	return
+}
+
+// unitDefinition returns the definition from Converter.UnitDefinitions with the name unit
+func (converter *Converter) unitDefinition(unit string) (definition UnitDefinition, ok bool) {
+	for _, definition = range converter.UnitDefinitions {
+		if definition.Name == unit {
+			ok = true
+			return
+		}
+	}
+
+	return UnitDefinition{}, false
+}
+
+// unitFromAlias returns the name of the unit in Converter.UnitDefinitions that has alias among its aliases
+func (converter *Converter) unitFromAlias(alias string) (unit string, ok bool) {
+	for _, definition := range converter.UnitDefinitions {
+		for _, definitionAlias := range definition.Aliases {
+			if definitionAlias == alias {
+				return definition.Name, true
+			}
+		}
+	}
+
+	return
+}
+
+// unitNames returns the names of all units that are used in a conversion or defined in Converter.UnitDefinitions, sorted by name
+func (converter *Converter) unitNames() (names []string) {
+	seen := map[string]bool{}
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	for index := range converter.Conversions {
+		add(converter.Conversions[index].From)
+		add(converter.Conversions[index].To)
+	}
+
+	for _, definition := range converter.UnitDefinitions {
+		add(definition.Name)
+	}
+
+	sort.Strings(names)
+
+	return
+}
+
+// reachableUnits returns the units that a quantity with the unit from can be converted to
+func (converter *Converter) reachableUnits(from string) (units []string) {
+	units = []string{}
+	visited := map[string]bool{from: true}
+	queue := []string{from}
+	for len(queue) > 0 {
+		unit := queue[0]
+		queue = queue[1:]
+
+		for index := range converter.Conversions {
+			conversion := &converter.Conversions[index]
+			if conversion.From == unit && !visited[conversion.To] {
+				visited[conversion.To] = true
+				queue = append(queue, conversion.To)
+				units = append(units, conversion.To)
+			}
+		}
+	}
+
+	sort.Strings(units)
+
+	return
+}
+
+// componentDimensions gives every unit the dimension of the units it is connected to by conversions in either direction, each group of connected units gets the dimension of its first unit by name that has one configured
+func (converter *Converter) componentDimensions(names []string) (dimensions map[string]string) {
+	neighbours := map[string][]string{}
+	for index := range converter.Conversions {
+		conversion := &converter.Conversions[index]
+		neighbours[conversion.From] = append(neighbours[conversion.From], conversion.To)
+		neighbours[conversion.To] = append(neighbours[conversion.To], conversion.From)
+	}
+
+	configured := map[string]string{}
+	for _, definition := range converter.UnitDefinitions {
+		if _, seen := configured[definition.Name]; !seen {
+			configured[definition.Name] = definition.Dimension
+		}
+	}
+
+	dimensions = map[string]string{}
+	visited := map[string]bool{}
+	for _, root := range names {
+		if visited[root] {
+			continue
+		}
+
+		component := []string{root}
+		visited[root] = true
+		for next := 0; next < len(component); next++ {
+			for _, neighbour := range neighbours[component[next]] {
+				if !visited[neighbour] {
+					visited[neighbour] = true
+					component = append(component, neighbour)
+				}
+			}
+		}
+
+		sort.Strings(component)
+		dimension := ""
+		for _, unit := range component {
+			if configured[unit] != "" {
+				dimension = configured[unit]
+				break
+			}
+		}
+
+		for _, unit := range component {
+			dimensions[unit] = dimension
+		}
+	}
+
+	return
+}
+
+// UnitNames lists the name of every unit that is used in a conversion or defined under units, sorted by name
+func (converter *Converter) UnitNames() []string {
+	return converter.unitNames()
+}
+
+// Units lists every known unit, units without a configured dimension get the dimension of the units they are connected to by conversions, the UCUM code of a unit is included among its aliases
+func (converter *Converter) Units() (units []UnitDefinition) {
+	units = []UnitDefinition{}
+	names := converter.unitNames()
+	dimensions := converter.componentDimensions(names)
+	for _, name := range names {
+		unit, _ := converter.unitDefinition(name)
+		unit.Name = name
+		unit.Aliases = append([]string{}, unit.Aliases...)
+
+		if code, _ := unitForms(name); code != name && !containsString(unit.Aliases, code) {
+			unit.Aliases = append(unit.Aliases, code)
+		}
+
+		if unit.Dimension == "" {
+			unit.Dimension = dimensions[name]
+		}
+
+		units = append(units, unit)
+	}
+
+	return
+}
+
+// ReachableUnits lists the units that a quantity with the unit from can be converted to, sorted by name
+func (converter *Converter) ReachableUnits(from string) (units []string, err error) {
+	resolvedFrom := converter.ResolveUnit(from)
+	if !converter.hasUnit(resolvedFrom) {
+		err = &UnknownUnitError{Unit: from}
+		return
+	}
+
+	units = converter.reachableUnits(resolvedFrom)
+
+	return
+}
+
+// SearchUnits lists the units where the name or an alias starts with prefix, ignoring case, an empty prefix lists all units
+func (converter *Converter) SearchUnits(prefix string) (units []UnitDefinition) {
+	units = []UnitDefinition{}
+	prefix = strings.ToLower(prefix)
+	for _, unit := range converter.Units() {
+		for _, name := range append([]string{unit.Name}, unit.Aliases...) {
+			if strings.HasPrefix(strings.ToLower(name), prefix) {
+				units = append(units, unit)
+				break
+			}
+		}
+	}
+
+	return
+}
+
+func containsString(list []string, text string) bool {
+	for _, item := range list {
+		if item == text {
+			return true
+		}
+	}
+
+	return false
+}
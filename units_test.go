@@ -0,0 +1,121 @@
+package unitconversion
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newUnitsTestConverter() Converter {
+	return Converter{
+		PreferredUnits: []string{"m"},
+		UnitDefinitions: []UnitDefinition{
+			UnitDefinition{Name: "m", Dimension: "length", Aliases: []string{"meter"}},
+			UnitDefinition{Name: "l", Dimension: "volume"},
+		},
+		Conversions: []Conversion{
+			Conversion{From: "cm", To: "m", Formula: "magnitude / 100"},
+			Conversion{From: "m", To: "cm", Formula: "magnitude * 100"},
+			Conversion{From: "in", To: "m", Formula: "magnitude * 0.0254"},
+			Conversion{From: "µg/l", To: "ng/ml", Formula: "magnitude"},
+		},
+	}
+}
+
+func TestConverterUnits(test *testing.T) {
+	converter := newUnitsTestConverter()
+
+	assert.Equal(test, []UnitDefinition{
+		UnitDefinition{Name: "cm", Dimension: "length", Aliases: []string{}},
+		UnitDefinition{Name: "in", Dimension: "length", Aliases: []string{"[in_i]"}},
+		UnitDefinition{Name: "l", Dimension: "volume", Aliases: []string{"L"}},
+		UnitDefinition{Name: "m", Dimension: "length", Aliases: []string{"meter"}},
+		UnitDefinition{Name: "ng/ml", Aliases: []string{"ng/mL"}},
+		UnitDefinition{Name: "µg/l", Aliases: []string{"ug/L"}},
+	}, converter.Units())
+}
+
+func TestConverterUnitsWithDimensionOfConnectedUnits(test *testing.T) {
+	converter := newUnitsTestConverter()
+	converter.Conversions = append(converter.Conversions, Conversion{From: "m", To: "ft", Formula: "magnitude / 0.3048"})
+
+	// ft can not be converted to any unit but it is connected to m
+	units := converter.Units()
+	assert.Equal(test, UnitDefinition{Name: "ft", Dimension: "length", Aliases: []string{"[ft_i]"}}, units[1])
+	assert.Equal(test, []string{"cm", "ft", "in", "l", "m", "ng/ml", "µg/l"}, converter.UnitNames())
+}
+
+func BenchmarkConverterUnits(benchmark *testing.B) {
+	converter, err := DefaultConverter()
+	if err != nil {
+		panic(err)
+	}
+
+	// Without configured dimensions every unit gets its dimension from the conversions
+	converter.UnitDefinitions = nil
+	for index := 0; index < benchmark.N; index++ {
+		converter.Units()
+	}
+}
+
+func TestConverterReachableUnits(test *testing.T) {
+	converter := newUnitsTestConverter()
+
+	units, err := converter.ReachableUnits("in")
+	assert.NoError(test, err)
+	assert.Equal(test, []string{"cm", "m"}, units)
+
+	units, err = converter.ReachableUnits("meter")
+	assert.NoError(test, err)
+	assert.Equal(test, []string{"cm"}, units)
+
+	units, err = converter.ReachableUnits("l")
+	assert.NoError(test, err)
+	assert.Equal(test, []string{}, units)
+}
+
+func TestFailConverterReachableUnitsWithUnknownUnit(test *testing.T) {
+	converter := newUnitsTestConverter()
+
+	units, err := converter.ReachableUnits("parsec")
+	assert.Error(test, err)
+	assert.Empty(test, units)
+}
+
+func TestConverterSearchUnits(test *testing.T) {
+	converter := newUnitsTestConverter()
+
+	names := func(units []UnitDefinition) (names []string) {
+		names = []string{}
+		for _, unit := range units {
+			names = append(names, unit.Name)
+		}
+
+		return
+	}
+
+	assert.Equal(test, []string{"m"}, names(converter.SearchUnits("M")))
+	assert.Equal(test, []string{"ng/ml"}, names(converter.SearchUnits("ng/M")))
+	assert.Equal(test, []string{"µg/l"}, names(converter.SearchUnits("ug")))
+	assert.Equal(test, []string{}, names(converter.SearchUnits("parsec")))
+	assert.Len(test, converter.SearchUnits(""), 6)
+}
+
+func TestConverterConvertWithAlias(test *testing.T) {
+	converter := newUnitsTestConverter()
+
+	output, err := converter.Convert(Quantity{Magnitude: 2, Unit: "meter"}, "cm")
+	assert.NoError(test, err)
+	assert.Equal(test, Quantity{Magnitude: 200, Unit: "cm"}, output)
+}
+
+func TestFailConverterTestWithAmbiguousAlias(test *testing.T) {
+	converter := Converter{
+		UnitDefinitions: []UnitDefinition{
+			UnitDefinition{Name: "m", Aliases: []string{"meter"}},
+			UnitDefinition{Name: "mi", Aliases: []string{"m"}},
+		},
+	}
+
+	assert.Error(test, converter.Test())
+}